@@ -0,0 +1,108 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// DefaultCachePath returns the default cache file path for the
+// application in slug, `<cache dir>/whatsnew/<owner>/<repo>.json`. Slugs
+// on hosts other than github.com are kept under the host, as in
+// `<cache dir>/whatsnew/<host>/<owner>/<repo>.json`. The cache dir is
+// XDG_CACHE_HOME if set, or else os.UserCacheDir.
+//
+// The `<APP>_WHATSNEW_CACHE` environment variable overrides the path,
+// where APP is the repository name in upper case, with characters other
+// than letters and digits replaced by underscores. For example,
+// `YOUR_APP_WHATSNEW_CACHE` for `you/your-app`.
+func DefaultCachePath(slug string) (string, error) {
+	host, slug, err := parseSlug(slug)
+	if err != nil {
+		return "", err
+	}
+
+	repo := path.Base(slug)
+	if p := os.Getenv(appEnv(repo, "WHATSNEW_CACHE")); p != "" {
+		return p, nil
+	}
+
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, slugFile(host, slug)), nil
+}
+
+// slugFile returns the relative path of the file for the application in
+// slug, `whatsnew/[<host>/]<owner>/<repo>.json`, so applications with the
+// same repository name don't share files.
+func slugFile(host, slug string) string {
+	if host == "github.com" {
+		host = ""
+	}
+
+	return filepath.Join("whatsnew", host, filepath.FromSlash(slug)+".json")
+}
+
+// defaultCacher returns a FileCacher for the DefaultCachePath of slug.
+// If there is no default path, or it is not writable, such as on a
+// read-only filesystem, results are cached in memory instead. Once the
+// cache file exists, its directory is not probed again.
+func defaultCacher(slug string) impl.Cacher {
+	p, err := DefaultCachePath(slug)
+	if err != nil {
+		return &impl.MemoryCacher{}
+	}
+
+	if _, err := os.Stat(p); err != nil && !writable(filepath.Dir(p)) {
+		return &impl.MemoryCacher{}
+	}
+
+	return &impl.FileCacher{Path: p}
+}
+
+// writable reports if files can be created in dir, creating it if
+// needed.
+func writable(dir string) bool {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return false
+	}
+
+	f, err := os.CreateTemp(dir, ".whatsnew-*")
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	os.Remove(f.Name())
+
+	return true
+}
+
+// appEnv returns the name of an application specific environment
+// variable, prefixing name with repo in upper case, and with characters
+// other than letters and digits replaced by underscores.
+func appEnv(repo, name string) string {
+	app := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, repo)
+
+	return app + "_" + name
+}
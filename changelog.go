@@ -0,0 +1,199 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jbowes/semver"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// DefaultChangelogWidth is the width used by WriteChangelog if no width
+// is given.
+const DefaultChangelogWidth = 80
+
+// Changelog returns the releases newer than Options.Version, up to and
+// including the newer version found by the Check, in semver order. If no
+// update is available, it is empty. Drafts, and prereleases not allowed
+// by Options.Flags, are skipped.
+//
+// Releases come from the Check, or the cache if Options.CacheReleases is
+// set. If they don't reach back to Options.Version, and the Releaser
+// implements impl.History, older releases are fetched. If that fails,
+// the changelog from the releases already known is returned with the
+// error.
+//
+// Like Result, Changelog blocks waiting for the Check to complete.
+func (f *Future) Changelog(ctx context.Context) ([]impl.Release, error) {
+	res, err := f.Result()
+	if err != nil || res.Semver == nil {
+		return nil, err
+	}
+
+	return f.r.between(ctx, f.r.cur, res.Semver)
+}
+
+// UpgradeNotes returns the releases newer than Result.Previous, up to
+// and including Options.Version, in semver order, if Result.Upgraded
+// is set. Otherwise, it is empty. Releases are found as with Changelog.
+//
+// Like Result, UpgradeNotes blocks waiting for the Check to complete.
+func (f *Future) UpgradeNotes(ctx context.Context) ([]impl.Release, error) {
+	res, err := f.Result()
+	if err != nil || !res.Upgraded {
+		return nil, err
+	}
+
+	_, prev, _ := parseV(res.Previous)
+	return f.r.between(ctx, prev, f.r.cur)
+}
+
+// between returns the eligible releases after low, up to and including
+// high, in semver order. If the releases known from the Check don't
+// reach back to low, older releases are fetched if the Releaser
+// implements impl.History.
+func (r *result) between(ctx context.Context, low, high *semver.Version) ([]impl.Release, error) {
+	rels := r.rels
+	if h, ok := r.opts.Releaser.(impl.History); ok && !reaches(rels, low) {
+		more, err := h.History(ctx, func(rels []impl.Release) bool {
+			return reaches(rels, low)
+		})
+		if err != nil {
+			return changelog(rels, r.opts.Flags, low, high), err
+		}
+
+		rels = sanitizeReleases(more)
+	}
+
+	return changelog(rels, r.opts.Flags, low, high), nil
+}
+
+// reaches reports if rels include a release at or before cur.
+func reaches(rels []impl.Release, cur *semver.Version) bool {
+	if cur == nil {
+		return false
+	}
+
+	for _, rel := range rels {
+		if _, v, err := parseV(rel.TagName); err == nil && v.Compare(cur) <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changelog returns the eligible releases in rels after cur, up to and
+// including latest, in semver order. latest itself is always eligible.
+func changelog(rels []impl.Release, flags Flag, cur, latest *semver.Version) []impl.Release {
+	type versioned struct {
+		rel impl.Release
+		v   *semver.Version
+	}
+
+	seen := map[string]bool{}
+	var vs []versioned
+	for _, rel := range rels {
+		_, v, err := parseV(rel.TagName)
+		switch {
+		case err != nil: // not a valid semver tag
+		case rel.Draft:
+		case seen[v.String()]:
+		case v.Compare(latest) != 0 && !flags.allows(cur, v, rel.Prerelease):
+		case cur.Compare(v) >= 0, v.Compare(latest) > 0:
+		default:
+			seen[v.String()] = true
+			vs = append(vs, versioned{rel, v})
+		}
+	}
+
+	sort.SliceStable(vs, func(a, b int) bool { return vs[a].v.Compare(vs[b].v) < 0 })
+
+	out := make([]impl.Release, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, v.rel)
+	}
+
+	return out
+}
+
+// WriteChangelog writes rels as plain text to w, wrapping release notes
+// to fit within width columns. If width is not positive,
+// DefaultChangelogWidth is used.
+//
+// Each release is written as a heading with its tag, name and publish
+// date, followed by its indented notes.
+func WriteChangelog(w io.Writer, rels []impl.Release, width int) error {
+	if width <= 0 {
+		width = DefaultChangelogWidth
+	}
+
+	bw := bufio.NewWriter(w)
+	for n, rel := range rels {
+		if n > 0 {
+			bw.WriteString("\n")
+		}
+
+		heading := rel.TagName
+		if rel.Name != "" && rel.Name != rel.TagName {
+			heading += " - " + rel.Name
+		}
+		if !rel.PublishedAt.IsZero() {
+			heading += " (" + rel.PublishedAt.Format("2006-01-02") + ")"
+		}
+		wrap(bw, heading, "", width)
+
+		body := strings.TrimSpace(strings.ReplaceAll(rel.Body, "\r\n", "\n"))
+		if body == "" {
+			continue
+		}
+
+		for _, line := range strings.Split(body, "\n") {
+			wrap(bw, line, "  ", width)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// wrap writes line to w, prefixed with indent, and word wrapped to
+// width columns. Leading whitespace in line is kept on each wrapped
+// line. Words longer than width are not broken.
+func wrap(w *bufio.Writer, line, indent string, width int) {
+	line = strings.TrimRight(line, " \t")
+	trimmed := strings.TrimLeft(line, " \t")
+	indent += line[:len(line)-len(trimmed)]
+
+	cols := 0
+	for _, word := range strings.Fields(trimmed) {
+		n := utf8.RuneCountInString(word)
+		switch {
+		case cols == 0:
+		case cols+1+n > width:
+			w.WriteString("\n")
+			cols = 0
+		default:
+			w.WriteString(" ")
+			cols++
+		}
+
+		if cols == 0 {
+			w.WriteString(indent)
+			cols = utf8.RuneCountInString(indent)
+		}
+
+		w.WriteString(word)
+		cols += n
+	}
+
+	w.WriteString("\n")
+}
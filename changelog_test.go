@@ -0,0 +1,240 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew"
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// tags returns the tag names of rels, comma separated.
+func tags(rels []impl.Release) string {
+	var out []string
+	for _, rel := range rels {
+		out = append(out, rel.TagName)
+	}
+
+	return strings.Join(out, ",")
+}
+
+func TestFuture_Changelog(t *testing.T) {
+	rels := []impl.Release{
+		{TagName: "v1.1.0", Body: "minor"},
+		{TagName: "v1.2.0-rc.1", Prerelease: true},
+		{TagName: "v1.0.2", Draft: true},
+		{TagName: "v0.9.0"},
+		{TagName: "not-semver"},
+		{TagName: "v1.0.0"},
+		{TagName: "v1.0.1", Body: "patch"},
+	}
+
+	tcs := map[string]struct {
+		version string
+		flags   whatsnew.Flag
+		out     string
+	}{
+		"newer":            {"v1.0.0", whatsnew.NoFlags, "v1.0.1,v1.1.0"},
+		"older":            {"v0.8.0", whatsnew.NoFlags, "v0.9.0,v1.0.0,v1.0.1,v1.1.0"},
+		"latest":           {"v1.1.0", whatsnew.NoFlags, ""},
+		"into prereleases": {"v1.0.0", whatsnew.IntoPrerelease, "v1.0.1,v1.1.0,v1.2.0-rc.1"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  tc.version,
+				Flags:    tc.flags,
+				Cacher:   &testCacher{info: &impl.Info{}},
+				Releaser: &testReleaser{releases: rels},
+			})
+
+			out, err := fut.Changelog(ctx)
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if got := tags(out); got != tc.out {
+				t.Errorf("changelog did not match. got: %s, want: %s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestFuture_ChangelogFromCache(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:       "v1.0.0",
+		CacheReleases: true,
+		Cacher: &testCacher{info: &impl.Info{
+			CheckTime: time.Now(),
+			Version:   "v1.1.0",
+			Releases:  []impl.Release{{TagName: "v1.1.0"}, {TagName: "v1.0.1"}, {TagName: "v1.0.0"}},
+		}},
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	out, err := fut.Changelog(ctx)
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if got, want := tags(out), "v1.0.1,v1.1.0"; got != want {
+		t.Errorf("changelog did not match. got: %s, want: %s", got, want)
+	}
+}
+
+func TestFuture_ChangelogErr(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.0.0",
+		Slug:    "you/your-app",
+		Cache:   "unused-cache.json",
+		Cacher:  &testCacher{info: &impl.Info{}},
+	})
+
+	if _, err := fut.Changelog(ctx); !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
+	}
+}
+
+func TestFuture_UpgradeNotes(t *testing.T) {
+	rels := []impl.Release{
+		{TagName: "v1.3.0"},
+		{TagName: "v1.3.0-rc.1", Prerelease: true},
+		{TagName: "v1.2.0"},
+		{TagName: "v1.1.1", Draft: true},
+		{TagName: "v1.1.0"},
+		{TagName: "v1.0.0"},
+	}
+
+	tcs := map[string]struct {
+		lastRun string
+		version string
+		out     string
+	}{
+		"upgrade":            {"v1.0.0", "v1.2.0", "v1.1.0,v1.2.0"},
+		"upgrade prerelease": {"v1.2.0", "v1.3.0-rc.1", "v1.3.0-rc.1"},
+		"no upgrade":         {"v1.2.0", "v1.2.0", ""},
+		"first run":          {"", "v1.2.0", ""},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  tc.version,
+				Cacher:   &testCacher{info: &impl.Info{LastRunVersion: tc.lastRun}},
+				Releaser: &testReleaser{releases: rels},
+			})
+
+			out, err := fut.UpgradeNotes(ctx)
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if got := tags(out); got != tc.out {
+				t.Errorf("notes did not match. got: %s, want: %s", got, tc.out)
+			}
+		})
+	}
+}
+
+// historyServer serves pages of two releases each, from v1.5.0 down to
+// v1.0.0, and counts the pages requested.
+func historyServer(t *testing.T, pages *int) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*pages++
+
+		page := 0
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		if page < 2 {
+			page = 1
+		}
+
+		minor := 5 - 2*(page-1)
+		if minor > 1 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/releases?page=%d>; rel="next"`, srv.URL, page+1))
+		}
+
+		fmt.Fprintf(w, `[{"tag_name": "v1.%d.0"}, {"tag_name": "v1.%d.0"}]`, minor, minor-1)
+	}))
+
+	return srv
+}
+
+func TestFuture_ChangelogHistory(t *testing.T) {
+	var pages int
+	srv := historyServer(t, &pages)
+	defer srv.Close()
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.2.0",
+		Cacher:  &testCacher{info: &impl.Info{}},
+		Releaser: &impl.GitHubReleaser{
+			URL:      srv.URL + "/releases",
+			Client:   srv.Client(),
+			MaxPages: 1,
+		},
+	})
+
+	out, err := fut.Changelog(ctx)
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if got, want := tags(out), "v1.3.0,v1.4.0,v1.5.0"; got != want {
+		t.Errorf("changelog did not match. got: %s, want: %s", got, want)
+	}
+
+	// One page for the check, then two for history, stopping once
+	// v1.2.0 is found.
+	if pages != 3 {
+		t.Errorf("pages requested did not match. got: %d, want: %d", pages, 3)
+	}
+}
+
+func TestWriteChangelog(t *testing.T) {
+	rels := []impl.Release{
+		{
+			TagName:     "v1.0.1",
+			Name:        "Patch",
+			PublishedAt: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+			Body:        "Fixes a crash when the config file is missing.\r\n\r\n- Faster startup\r\n- Quieter logs with a long explanation",
+		},
+		{TagName: "v1.1.0", Name: "v1.1.0"},
+		{TagName: "v1.2.0", Body: "https://example.com/a/very/long/link/that/does/not/fit"},
+	}
+
+	var buf bytes.Buffer
+	if err := whatsnew.WriteChangelog(&buf, rels, 30); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	want := `v1.0.1 - Patch (2021-03-01)
+  Fixes a crash when the
+  config file is missing.
+
+  - Faster startup
+  - Quieter logs with a long
+  explanation
+
+v1.1.0
+
+v1.2.0
+  https://example.com/a/very/long/link/that/does/not/fit
+`
+	if buf.String() != want {
+		t.Errorf("changelog did not match. got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
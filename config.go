@@ -0,0 +1,151 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jbowes/semver"
+)
+
+// Release channels for UserConfig.
+const (
+	ChannelStable     = "stable"     // Only stable releases are reported.
+	ChannelPrerelease = "prerelease" // Prereleases are reported, as with IntoPrerelease.
+)
+
+// UserConfig is the format of a user config file for release checks. It
+// lets users of your application control checks, without flags or code
+// in your application. For example:
+//
+//	{
+//	  "disable": false,
+//	  "frequency": "24h",
+//	  "channel": "prerelease",
+//	  "ignore": ["v2.0.0"]
+//	}
+//
+// Settings in the config file take precedence over Options. Opt outs in
+// the environment, as described in Check, take precedence over the
+// config file.
+type UserConfig struct {
+	Disable   bool     `json:"disable"`   // Disable release checks.
+	Frequency string   `json:"frequency"` // How often to check, as a duration, eg `24h`.
+	Channel   string   `json:"channel"`   // ChannelStable or ChannelPrerelease.
+	Ignore    []string `json:"ignore"`    // Versions to never report.
+}
+
+// DefaultConfigPath returns the default user config file path for the
+// application in slug, `<config dir>/whatsnew/<owner>/<repo>.json`, or
+// under the host for hosts other than github.com, as for
+// DefaultCachePath. The config dir is XDG_CONFIG_HOME if set, or else
+// os.UserConfigDir.
+//
+// The `<APP>_WHATSNEW_CONFIG` environment variable overrides the path,
+// where APP is derived from slug as for DefaultCachePath.
+func DefaultConfigPath(slug string) (string, error) {
+	host, slug, err := parseSlug(slug)
+	if err != nil {
+		return "", err
+	}
+
+	repo := path.Base(slug)
+	if p := os.Getenv(appEnv(repo, "WHATSNEW_CONFIG")); p != "" {
+		return p, nil
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, slugFile(host, slug)), nil
+}
+
+// readConfig reads the user config file at p. If there is no file, a
+// nil UserConfig is returned.
+func readConfig(p string) (*UserConfig, error) {
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c UserConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", p, err)
+	}
+
+	return &c, nil
+}
+
+// applyConfig applies the user config file at p to o. Invalid configs
+// are not applied, and are reported in Result.ConfigErr.
+func (o *Options) applyConfig(p string) {
+	c, err := readConfig(p)
+	if err != nil || c == nil {
+		o.configErr = err
+		return
+	}
+
+	var freq time.Duration
+	if c.Frequency != "" {
+		if freq, err = time.ParseDuration(c.Frequency); err != nil || freq <= 0 {
+			o.configErr = fmt.Errorf("invalid config %s: bad frequency %q", p, c.Frequency)
+			return
+		}
+	}
+
+	var ignore []*semver.Version
+	for _, v := range c.Ignore {
+		_, sv, err := parseV(v)
+		if err != nil {
+			o.configErr = fmt.Errorf("invalid config %s: bad ignored version %q", p, v)
+			return
+		}
+		ignore = append(ignore, sv)
+	}
+
+	switch c.Channel {
+	case "":
+	case ChannelStable:
+		o.Flags = NoFlags
+	case ChannelPrerelease:
+		o.Flags |= IntoPrerelease
+	default:
+		o.configErr = fmt.Errorf("invalid config %s: unknown channel %q", p, c.Channel)
+		return
+	}
+
+	if c.Disable {
+		o.disabled = "disabled in " + p
+	}
+	if freq != 0 {
+		o.Frequency = freq
+	}
+	o.ignore = ignore
+}
+
+// ignored reports if v is ignored in the user config.
+func (o *Options) ignored(v *semver.Version) bool {
+	for _, iv := range o.ignore {
+		if iv.Compare(v) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
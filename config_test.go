@@ -0,0 +1,298 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew"
+	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "whatsnew.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal("couldn't write config")
+	}
+
+	return path
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	tcs := map[string]struct {
+		slug     string
+		xdg      string
+		override string
+		out      string
+	}{
+		"xdg config home": {"you/your-app", "/xdg/config", "", filepath.Join("/xdg/config", "whatsnew", "you", "your-app.json")},
+		"slug url":        {"https://github.com/you/your-app.git", "/xdg/config", "", filepath.Join("/xdg/config", "whatsnew", "you", "your-app.json")},
+		"other owner":     {"them/your-app", "/xdg/config", "", filepath.Join("/xdg/config", "whatsnew", "them", "your-app.json")},
+		"app override":    {"you/your-app", "/xdg/config", "/elsewhere/config.json", "/elsewhere/config.json"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testenv.Setenv(t, "XDG_CONFIG_HOME", tc.xdg)
+			testenv.Setenv(t, "YOUR_APP_WHATSNEW_CONFIG", tc.override)
+
+			out, err := whatsnew.DefaultConfigPath(tc.slug)
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if out != tc.out {
+				t.Errorf("paths did not match. got: %s, want: %s", out, tc.out)
+			}
+		})
+	}
+}
+
+func TestCheck_config(t *testing.T) {
+	releases := []impl.Release{
+		{TagName: "v1.2.0-rc.1", Prerelease: true},
+		{TagName: "v1.1.0"},
+		{TagName: "v1.0.1"},
+	}
+
+	tcs := map[string]struct {
+		config  string
+		flags   whatsnew.Flag
+		checked time.Time
+		version string
+	}{
+		"no config":           {"", whatsnew.NoFlags, time.Time{}, "v1.1.0"},
+		"empty":               {`{}`, whatsnew.NoFlags, time.Time{}, "v1.1.0"},
+		"prerelease channel":  {`{"channel": "prerelease"}`, whatsnew.NoFlags, time.Time{}, "v1.2.0-rc.1"},
+		"stable channel":      {`{"channel": "stable"}`, whatsnew.IntoPrerelease, time.Time{}, "v1.1.0"},
+		"ignore":              {`{"ignore": ["1.1.0"]}`, whatsnew.NoFlags, time.Time{}, "v1.0.1"},
+		"ignore all":          {`{"ignore": ["v1.1.0", "v1.0.1"]}`, whatsnew.NoFlags, time.Time{}, ""},
+		"frequency":           {`{"frequency": "1h"}`, whatsnew.NoFlags, time.Now().Add(-2 * time.Hour), "v1.1.0"},
+		"frequency not yet":   {`{"frequency": "4h"}`, whatsnew.NoFlags, time.Now().Add(-2 * time.Hour), ""},
+		"ignore cached":       {`{"ignore": ["v1.1.0"]}`, whatsnew.NoFlags, time.Now(), "v1.0.1"},
+		"default not checked": {"", whatsnew.NoFlags, time.Now().Add(-2 * time.Hour), ""},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.json")
+			if tc.config != "" {
+				path = writeConfig(t, tc.config)
+			}
+
+			// A recent check has the cached version, and a release
+			// check won't run.
+			info := &impl.Info{}
+			if !tc.checked.IsZero() {
+				info = &impl.Info{CheckTime: tc.checked}
+				if tc.checked.After(time.Now().Add(-time.Hour)) {
+					info.Version = "v1.1.0"
+				}
+			}
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:    "v1.0.0",
+				ConfigPath: path,
+				Flags:      tc.flags,
+				Cacher:     &testCacher{info: info},
+				Releaser:   &testReleaser{releases: releases},
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if res.ConfigErr != nil {
+				t.Errorf("expected nil config error. got: %s", res.ConfigErr)
+			}
+			if res.Version != tc.version {
+				t.Errorf("versions did not match. got: %s, want: %s", res.Version, tc.version)
+			}
+		})
+	}
+}
+
+func TestCheck_configChanged(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{}}
+	releaser := &testReleaser{
+		releases: []impl.Release{
+			{TagName: "v1.2.0-rc.1", Prerelease: true},
+			{TagName: "v1.1.0"},
+			{TagName: "v1.0.1"},
+		},
+		notModified: true,
+	}
+
+	for _, tc := range []struct {
+		config string
+		out    string
+	}{
+		{`{}`, "v1.1.0"},
+		{`{"ignore": ["v1.1.0"]}`, "v1.0.1"},
+		{`{}`, "v1.1.0"},
+		{`{"channel": "prerelease"}`, "v1.2.0-rc.1"},
+		{`{"channel": "stable"}`, "v1.1.0"},
+	} {
+		if cacher.set != nil {
+			cacher.info = cacher.set
+		}
+
+		fut := whatsnew.Check(ctx, &whatsnew.Options{
+			Version:    "v1.0.0",
+			ConfigPath: writeConfig(t, tc.config),
+			Cacher:     cacher,
+			Releaser:   releaser,
+		})
+
+		res, err := fut.Get()
+		if err != nil {
+			t.Fatalf("expected nil error. got: %s", err)
+		}
+		if res != tc.out {
+			t.Errorf("versions did not match with config %s. got: %s, want: %s", tc.config, res, tc.out)
+		}
+	}
+}
+
+func TestCheck_configDisable(t *testing.T) {
+	path := writeConfig(t, `{"disable": true}`)
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:    "v1.0.0",
+		ConfigPath: path,
+		Cacher:     &testCacher{info: &impl.Info{}},
+		Releaser:   &testReleaser{releases: []impl.Release{{TagName: "v1.1.0"}}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	want := "disabled in " + path
+	if res.Skipped != want {
+		t.Errorf("skip reason did not match. got: %q, want: %q", res.Skipped, want)
+	}
+	if res.Version != "" {
+		t.Errorf("expected no version. got: %s", res.Version)
+	}
+}
+
+func TestCheck_configEnvTakesPrecedence(t *testing.T) {
+	testenv.Setenv(t, "YOUR_APP_NO_UPDATE_CHECK", "1")
+	path := writeConfig(t, `{"disable": false}`)
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:       "you/your-app",
+		Version:    "v1.0.0",
+		ConfigPath: path,
+		Cacher:     &testCacher{info: &impl.Info{}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	want := "YOUR_APP_NO_UPDATE_CHECK is set"
+	if res.Skipped != want {
+		t.Errorf("skip reason did not match. got: %q, want: %q", res.Skipped, want)
+	}
+}
+
+func TestCheck_configDefaultPath(t *testing.T) {
+	dir := t.TempDir()
+	testenv.Setenv(t, "XDG_CONFIG_HOME", dir)
+	testenv.Setenv(t, "YOUR_APP_WHATSNEW_CONFIG", "")
+
+	if err := os.MkdirAll(filepath.Join(dir, "whatsnew", "you"), 0750); err != nil {
+		t.Fatal("couldn't set up config home")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "whatsnew", "you", "your-app.json"), []byte(`{"disable": true}`), 0600); err != nil {
+		t.Fatal("couldn't write config")
+	}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-app",
+		Version: "v1.0.0",
+		Cacher:  &testCacher{info: &impl.Info{}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Skipped == "" {
+		t.Errorf("expected check to be skipped")
+	}
+}
+
+func TestCheck_configInvalid(t *testing.T) {
+	tcs := map[string]string{
+		"bad json":       `{"disable": `,
+		"bad type":       `{"frequency": 24}`,
+		"bad frequency":  `{"frequency": "daily", "disable": true}`,
+		"zero frequency": `{"frequency": "0s"}`,
+		"bad channel":    `{"channel": "nightly", "disable": true}`,
+		"bad ignore":     `{"ignore": ["latest"], "disable": true}`,
+	}
+
+	for name, config := range tcs {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfig(t, config)
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:    "v1.0.0",
+				ConfigPath: path,
+				Cacher:     &testCacher{info: &impl.Info{}},
+				Releaser:   &testReleaser{releases: []impl.Release{{TagName: "v1.1.0"}}},
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if res.ConfigErr == nil {
+				t.Errorf("expected config error")
+			}
+
+			// Invalid configs aren't used.
+			if res.Skipped != "" || res.Version != "v1.1.0" {
+				t.Errorf("expected config to be ignored. got: %s (skipped: %q)", res.Version, res.Skipped)
+			}
+		})
+	}
+}
+
+func TestCheck_configUnreadable(t *testing.T) {
+	// A directory can't be read as a config file.
+	dir := t.TempDir()
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:    "v1.0.0",
+		ConfigPath: dir,
+		Cacher:     &testCacher{info: &impl.Info{}},
+		Releaser:   &testReleaser{releases: []impl.Release{{TagName: "v1.1.0"}}},
+	})
+
+	res, _ := fut.Result()
+	if res.ConfigErr == nil || errors.Is(res.ConfigErr, os.ErrNotExist) {
+		t.Errorf("expected config error. got: %v", res.ConfigErr)
+	}
+}
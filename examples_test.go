@@ -8,10 +8,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/jbowes/whatsnew"
 	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
 )
 
 func Example() {
@@ -32,6 +34,55 @@ func Example() {
 	// new release available: v0.2.0
 }
 
+// Use the detailed Result to only show a notice when the result is
+// fresh, rather than on every run.
+func ExampleFuture_Result() {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-app",
+		Cache:   "testdata/update-cache-result.json",
+		Version: "v0.0.1",
+	})
+
+	// Run your CLI code and whatnot
+
+	if res, _ := fut.Result(); res.Version != "" && !res.Cached {
+		fmt.Printf("new release available: %s\n", res.Version)
+	}
+
+	// Output:
+	// new release available: 0.30.0
+}
+
+// Show what changed between the running version and the newer release.
+func ExampleFuture_Changelog() {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-tool",
+		Cache:   "testdata/update-cache-changelog.json",
+		Version: "v0.28.0",
+	})
+
+	// Run your CLI code and whatnot
+
+	if rels, _ := fut.Changelog(ctx); len(rels) != 0 {
+		fmt.Printf("new releases available:\n\n")
+		_ = whatsnew.WriteChangelog(os.Stdout, rels, 40)
+	}
+
+	// Output:
+	// new releases available:
+	//
+	// 0.29.0 - Twenty-nine (2021-03-01)
+	//   Fixes for the *old* things.
+	//
+	//   - Faster startup
+	//   - Quieter logs
+	//
+	// 0.30.0 - Thirty (2021-04-01)
+	//   Lots of *new* things.
+}
+
 // This example test isn't really needed, but it keeps the file
 // from being an example program, so we can replace the http
 // transport etc.
@@ -68,8 +119,16 @@ func init() {
 		Etag:      "whatever",
 	})
 
+	// and start with no cache for the detailed result and changelog.
+	_ = os.Remove("testdata/update-cache-result.json")
+	_ = os.Remove("testdata/update-cache-changelog.json")
+
 	// replace http default transport.
 	http.DefaultTransport = http.NewFileTransport(
 		http.Dir("testdata/example"),
 	)
+
+	// don't skip checks when the tests run in CI, or read the user
+	// config of whoever runs them.
+	testenv.Clear("testdata/no-config")
 }
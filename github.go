@@ -0,0 +1,93 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultGitHubAPIURL is the GitHub API used if no other is configured.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// parseSlug normalises a repository slug, returning the host from the
+// slug, if any, and the `owner/repo` form of the slug. Slugs may be
+// given as `owner/repo`, `host/owner/repo`, web or ssh URLs such as
+// `https://github.com/owner/repo`, or scp-like `git@host:owner/repo.git`.
+func parseSlug(slug string) (string, string, error) {
+	host := ""
+	path := slug
+
+	switch {
+	case strings.Contains(slug, "://"):
+		u, err := url.Parse(slug)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid slug %q: %w", slug, ErrMisconfiguredOptions)
+		}
+		host, path = u.Hostname(), u.Path
+	case strings.Contains(slug, ":"): // scp-like, eg git@host:owner/repo
+		i := strings.Index(slug, ":")
+		host, path = slug[:i], slug[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if host == "" && len(parts) == 3 && strings.Contains(parts[0], ".") {
+		host, parts = parts[0], parts[1:]
+	}
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid slug %q: %w", slug, ErrMisconfiguredOptions)
+	}
+
+	return host, parts[0] + "/" + parts[1], nil
+}
+
+// slugKey returns the SharedCache key for a slug parsed by parseSlug, so
+// every form of a slug shares one entry.
+func slugKey(host, slug string) string {
+	if host == "" || host == "github.com" {
+		return slug
+	}
+
+	return host + "/" + slug
+}
+
+// gitHubAPIURL returns the GitHub API URL to use, from, in order, the
+// explicit option, the slug's host, the GITHUB_API_URL environment
+// variable, or DefaultGitHubAPIURL.
+func gitHubAPIURL(opt, slugHost string) string {
+	switch {
+	case opt != "":
+		return strings.TrimSuffix(opt, "/")
+	case slugHost != "" && slugHost != "github.com":
+		return "https://" + slugHost + "/api/v3" // GitHub Enterprise Server
+	case os.Getenv("GITHUB_API_URL") != "":
+		return strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+	}
+
+	return DefaultGitHubAPIURL
+}
+
+// gitHubHost returns the GitHub host for an API URL, for use in token
+// discovery.
+func gitHubHost(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Hostname()
+	if host == "api.github.com" {
+		return "github.com"
+	}
+
+	return host
+}
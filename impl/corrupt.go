@@ -0,0 +1,104 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxClockSkew is how far in the future a cached CheckTime may be before
+// the cache is considered corrupt.
+const maxClockSkew = time.Minute
+
+// CorruptCacheError is returned by a Cacher when its cache is corrupt,
+// such as a truncated or empty file, or a CheckTime in the future from
+// clock skew. The corrupt cache is moved aside, and checks start fresh.
+type CorruptCacheError struct {
+	Path       string // The path of the corrupt cache.
+	Quarantine string // Where the corrupt cache was moved, if it was.
+	Err        error  // Why the cache is corrupt.
+}
+
+func (e *CorruptCacheError) Error() string {
+	if e.Quarantine == "" {
+		return fmt.Sprintf("corrupt cache %s: %s", e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("corrupt cache %s moved to %s: %s", e.Path, e.Quarantine, e.Err)
+}
+
+func (e *CorruptCacheError) Unwrap() error { return e.Err }
+
+// errEmptyCache is the CorruptCacheError.Err for empty cache files.
+var errEmptyCache = errors.New("empty file")
+
+// readValid reads the file at path, and checks it with valid. A corrupt
+// file is moved aside to a timestamped `.corrupt-` path, under the lock
+// for path, and a *CorruptCacheError is returned.
+func readValid(ctx context.Context, path string, valid func([]byte) error) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || valid(data) == nil {
+		return data, err
+	}
+
+	unlock, err := lockFile(ctx, path+".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process may have replaced the file before it was locked.
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := valid(data)
+	if verr == nil {
+		return data, nil
+	}
+
+	cerr := &CorruptCacheError{Path: path, Err: verr}
+
+	q := path + ".corrupt-" + time.Now().UTC().Format("20060102T150405.000000000Z")
+	if err := os.Rename(path, q); err == nil {
+		cerr.Quarantine = q
+	}
+
+	return nil, cerr
+}
+
+// decodeInfo decodes and checks the Info in data.
+func decodeInfo(data []byte, now time.Time) (*Info, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errEmptyCache
+	}
+
+	var i Info
+	if err := json.Unmarshal(data, &i); err != nil {
+		return nil, err
+	}
+
+	if err := checkTime(&i, now); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// checkTime returns an error if the CheckTime of i is in the future.
+func checkTime(i *Info, now time.Time) error {
+	if i.CheckTime.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("check time %s is in the future", i.CheckTime.Format(time.RFC3339))
+	}
+
+	return nil
+}
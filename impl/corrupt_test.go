@@ -0,0 +1,164 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+func TestFileCacher_corrupt(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tcs := map[string]string{
+		"zero bytes":        ``,
+		"whitespace":        "\n  \n",
+		"truncated":         `{"check_time": "2021-03-01T10:00:00Z", "vers`,
+		"trailing garbage":  `{"version": "v1.1.2"}{"version": "v1.1`,
+		"wrong type":        `["v1.1.2"]`,
+		"future check time": `{"check_time": "` + future + `", "version": "v1.1.2"}`,
+	}
+
+	for name, data := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "test-cache.json")
+			if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+				t.Fatal("couldn't set up cache")
+			}
+
+			fc := impl.FileCacher{Path: path}
+			_, err := fc.Get(ctx)
+
+			var cerr *impl.CorruptCacheError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected corrupt cache error. got: %v", err)
+			}
+
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected corrupt cache to be moved. got: %v", err)
+			}
+
+			if !strings.HasPrefix(cerr.Quarantine, path+".corrupt-") {
+				t.Errorf("unexpected quarantine path. got: %s", cerr.Quarantine)
+			}
+
+			b, err := os.ReadFile(cerr.Quarantine)
+			if err != nil || string(b) != data {
+				t.Errorf("expected corrupt cache to be kept. got: %q, err: %v", b, err)
+			}
+
+			// Start fresh.
+			if err := fc.Set(ctx, &impl.Info{CheckTime: time.Now(), Version: "v1.1.2"}); err != nil {
+				t.Fatalf("error running set: %s", err)
+			}
+			if _, err := fc.Get(ctx); err != nil {
+				t.Errorf("error running get: %s", err)
+			}
+		})
+	}
+}
+
+func TestFileCacher_smallClockSkew(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test-cache.json")
+
+	fc := impl.FileCacher{Path: path}
+	if err := fc.Set(ctx, &impl.Info{CheckTime: time.Now().Add(10 * time.Second)}); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	if _, err := fc.Get(ctx); err != nil {
+		t.Errorf("error running get: %s", err)
+	}
+}
+
+func TestKeyedFileCacher_clockSkew(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	now := time.Now().Round(0)
+	entries := []struct {
+		key string
+		i   *impl.Info
+	}{
+		{"you/a", &impl.Info{CheckTime: now, Version: "v1.0.0"}},
+		{"you/b", &impl.Info{CheckTime: now.Add(time.Hour), Version: "v2.0.0"}},
+		{"you/c", &impl.Info{CheckTime: now, Version: "v3.0.0"}},
+	}
+
+	for _, e := range entries[:2] {
+		kc := impl.KeyedFileCacher{Path: path, Key: e.key}
+		if err := kc.Set(ctx, e.i); err != nil {
+			t.Fatalf("error running set: %s", err)
+		}
+	}
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/b"}
+	_, err := kc.Get(ctx)
+
+	var cerr *impl.CorruptCacheError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected corrupt cache error for skewed entry. got: %v", err)
+	}
+	if cerr.Quarantine != "" {
+		t.Errorf("expected shared cache to be kept. got: %s", cerr.Quarantine)
+	}
+
+	// Setting another entry drops the skewed one.
+	kc = impl.KeyedFileCacher{Path: path, Key: entries[2].key}
+	if err := kc.Set(ctx, entries[2].i); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	for _, key := range []string{"you/a", "you/c"} {
+		kc := impl.KeyedFileCacher{Path: path, Key: key}
+		if _, err := kc.Get(ctx); err != nil {
+			t.Errorf("expected entry for %s to be kept. got: %s", key, err)
+		}
+	}
+
+	kc = impl.KeyedFileCacher{Path: path, Key: "you/b"}
+	if _, err := kc.Get(ctx); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected skewed entry to be dropped. got: %v", err)
+	}
+}
+
+func TestKeyedFileCacher_corrupt(t *testing.T) {
+	tcs := map[string]string{
+		"zero bytes": ``,
+		"truncated":  `{"entries": {"you/your-app": {"version": "v1.1.2"`,
+	}
+
+	for name, data := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "updates.json")
+			if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+				t.Fatal("couldn't set up cache")
+			}
+
+			kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+			_, err := kc.Get(ctx)
+
+			var cerr *impl.CorruptCacheError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected corrupt cache error. got: %v", err)
+			}
+
+			if _, err := os.Stat(cerr.Quarantine); err != nil {
+				t.Errorf("expected corrupt cache to be kept. got: %v", err)
+			}
+		})
+	}
+}
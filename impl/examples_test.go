@@ -11,6 +11,7 @@ import (
 
 	"github.com/jbowes/whatsnew"
 	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
 )
 
 // noopCache is a whatsnew Cacher that does nothing, ensuring we always
@@ -72,4 +73,8 @@ func init() {
 	http.DefaultTransport = http.NewFileTransport(
 		http.Dir("../testdata/example"),
 	)
+
+	// don't skip checks when the tests run in CI, or read the user
+	// config of whoever runs them.
+	testenv.Clear("../testdata/no-config")
 }
@@ -7,43 +7,156 @@ package impl
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // FileCacher is the default Cacher used in whatsnew.
+//
+// Updates are written to a temporary file and renamed into place, so
+// readers never see a partial write. Concurrent updates from multiple
+// processes are serialised with an advisory lock on a `.lock` file
+// next to Path, which is left in place. Leases on release checks are
+// held in a `.lease` file, which is removed when the check is done, or
+// left to expire if the process exits first. Corrupt files are moved
+// aside to a timestamped `.corrupt-` file.
 type FileCacher struct {
 	Path string
 }
 
-// Get cached release Info.
-func (f *FileCacher) Get(context.Context) (*Info, error) {
-	r, err := os.Open(f.Path)
+// Get cached release Info. Info written with an older SchemaVersion is
+// migrated. If the file is corrupt, it is moved aside, and a
+// *CorruptCacheError is returned.
+func (f *FileCacher) Get(ctx context.Context) (*Info, error) {
+	data, err := readValid(ctx, f.Path, func(data []byte) error {
+		_, err := decodeInfo(data, time.Now())
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer r.Close()
 
-	var i Info
-	dec := json.NewDecoder(r)
-	err = dec.Decode(&i)
-	return &i, err
+	i, err := decodeInfo(data, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	i.migrate()
+	return i, nil
 }
 
 // Set cached release Info.
-func (f *FileCacher) Set(_ context.Context, i *Info) error {
+func (f *FileCacher) Set(ctx context.Context, i *Info) error {
 	if err := os.MkdirAll(filepath.Dir(f.Path), 0750); err != nil {
 		return err
 	}
 
-	w, err := os.Create(f.Path)
+	unlock, err := lockFile(ctx, f.Path+".lock")
 	if err != nil {
 		return err
 	}
-	defer w.Close()
+	defer unlock()
+
+	return writeJSON(f.Path, i)
+}
+
+// Lease the release check, by writing a `.lease` file next to Path.
+func (f *FileCacher) Lease(ctx context.Context, ttl time.Duration) (func(), bool, error) {
+	return leaseFile(ctx, f.Path, f.Path+".lease", ttl)
+}
+
+// lease is the contents of a lease file.
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// leaseFile takes a lease on path, held in the file at leasePath, if
+// there is no unexpired lease. Leases are updated under the lock for
+// path.
+func leaseFile(ctx context.Context, path, leasePath string, ttl time.Duration) (func(), bool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, false, err
+	}
+
+	unlock, err := lockFile(ctx, path+".lock")
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	var l lease
+	if err := readJSON(leasePath, &l); err == nil && time.Now().Before(l.Expires) {
+		return nil, false, nil
+	}
+
+	l = lease{
+		Owner:   fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano()),
+		Expires: time.Now().Add(ttl),
+	}
+	if err := writeJSON(leasePath, &l); err != nil {
+		return nil, false, err
+	}
+
+	release := func() {
+		// Use a fresh context; the check's may be done already.
+		unlock, err := lockFile(context.Background(), path+".lock")
+		if err != nil {
+			return
+		}
+		defer unlock()
+
+		// Only remove the lease if it is still ours.
+		var cur lease
+		if err := readJSON(leasePath, &cur); err == nil && cur.Owner == l.Owner {
+			_ = os.Remove(leasePath)
+		}
+	}
+
+	return release, true, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := json.NewDecoder(r)
+	return dec.Decode(v)
+}
+
+// writeJSON atomically replaces the file at path with the JSON encoding
+// of v, by writing to a temporary file and renaming it into place.
+func writeJSON(path string, v interface{}) (err error) {
+	w, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			w.Close()
+			os.Remove(w.Name())
+		}
+	}()
 
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 
-	return enc.Encode(i)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	if err := w.Sync(); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(w.Name(), path)
 }
@@ -8,7 +8,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -89,3 +92,160 @@ func TestFileCacher_errOnMkDir(t *testing.T) {
 		t.Errorf("expected err but go none")
 	}
 }
+
+func TestFileCacher_lease(t *testing.T) {
+	ctx := context.Background()
+	fc := impl.FileCacher{Path: filepath.Join(t.TempDir(), "cache", "test-cache.json")}
+
+	release, ok, err := fc.Lease(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected lease. got: %t, err: %v", ok, err)
+	}
+
+	if _, ok, err := fc.Lease(ctx, time.Minute); err != nil || ok {
+		t.Fatalf("expected lease to be held. got: %t, err: %v", ok, err)
+	}
+
+	release()
+
+	release, ok, err = fc.Lease(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected lease after release. got: %t, err: %v", ok, err)
+	}
+	release()
+}
+
+func TestFileCacher_leaseExpires(t *testing.T) {
+	ctx := context.Background()
+	fc := impl.FileCacher{Path: filepath.Join(t.TempDir(), "test-cache.json")}
+
+	stale, ok, err := fc.Lease(ctx, time.Millisecond)
+	if err != nil || !ok {
+		t.Fatalf("expected lease. got: %t, err: %v", ok, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	release, ok, err := fc.Lease(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected lease after expiry. got: %t, err: %v", ok, err)
+	}
+
+	// Releasing the expired lease must not release the new one.
+	stale()
+	if _, ok, err := fc.Lease(ctx, time.Minute); err != nil || ok {
+		t.Fatalf("expected lease to be held. got: %t, err: %v", ok, err)
+	}
+
+	release()
+}
+
+// stressInfo returns a large Info, so that partial writes are likely to
+// be seen if writes are not atomic.
+func stressInfo(proc, n int) *impl.Info {
+	i := &impl.Info{
+		CheckTime: time.Now(),
+		Version:   fmt.Sprintf("v%d.%d.0", proc, n),
+		Etag:      strings.Repeat("e", 4096),
+	}
+
+	for r := 0; r < 100; r++ {
+		i.Retractions = append(i.Retractions, impl.Retraction{
+			Low:       fmt.Sprintf("v0.%d.0", r),
+			High:      fmt.Sprintf("v0.%d.9", r),
+			Rationale: strings.Repeat("r", 100),
+		})
+	}
+
+	return i
+}
+
+// TestFileCacher_helperProcess is run as a subprocess by
+// TestFileCacher_multiProcess.
+func TestFileCacher_helperProcess(t *testing.T) {
+	path := os.Getenv("WHATSNEW_HELPER_CACHE")
+	if path == "" {
+		return
+	}
+
+	var proc int
+	fmt.Sscan(os.Getenv("WHATSNEW_HELPER_PROC"), &proc)
+
+	ctx := context.Background()
+	fc := impl.FileCacher{Path: path}
+	for n := 0; n < 50; n++ {
+		if err := fc.Set(ctx, stressInfo(proc, n)); err != nil {
+			t.Fatalf("error running set: %s", err)
+		}
+
+		i, err := fc.Get(ctx)
+		if err != nil {
+			t.Fatalf("error running get: %s", err)
+		}
+		if len(i.Retractions) != 100 {
+			t.Fatalf("partial read: %d retractions", len(i.Retractions))
+		}
+	}
+}
+
+func TestFileCacher_multiProcess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process test in short mode")
+	}
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache", "test-cache.json")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for proc := 0; proc < 8; proc++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFileCacher_helperProcess$")
+		cmd.Env = append(os.Environ(),
+			"WHATSNEW_HELPER_CACHE="+path,
+			fmt.Sprintf("WHATSNEW_HELPER_PROC=%d", proc),
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("%s: %s", err, out)
+			}
+		}()
+	}
+
+	// Read while the helpers write.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	fc := impl.FileCacher{Path: path}
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+
+		i, err := fc.Get(ctx)
+		switch {
+		case os.IsNotExist(err): // not written yet
+		case err != nil:
+			t.Fatalf("error running get: %s", err)
+		case len(i.Retractions) != 100:
+			t.Fatalf("partial read: %d retractions", len(i.Retractions))
+		}
+	}
+
+	close(errs)
+	for err := range errs {
+		t.Errorf("helper process failed: %s", err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaPageSize is the number of releases requested per page. It is the
+// default maximum for Gitea instances.
+const giteaPageSize = 50
+
+// GiteaReleaser is a Releaser for the Gitea releases API, as used by
+// Gitea, Forgejo, and Codeberg.
+type GiteaReleaser struct {
+	BaseURL string       // the base URL of the Gitea instance, eg `https://codeberg.org`
+	Repo    string       // the repository, eg `owner/repo`
+	Token   string       // optional. an access token.
+	Client  *http.Client // if not set, http.DefaultClient is used.
+
+	// MaxPages limits how many pages of releases are fetched. If not set,
+	// DefaultMaxPages is used.
+	MaxPages int
+}
+
+// Get a list of releases.
+//
+// Releases are fetched page by page, until there are no more pages, or
+// MaxPages is reached. The etag only applies to the first page; if it
+// has not changed, no further pages are fetched.
+func (g *GiteaReleaser) Get(ctx context.Context, etag string) ([]Release, string, error) {
+	c := g.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	maxPages := g.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	return getPages(ctx, g.pageURL(1), etag, maxPages, func(ctx context.Context, url, etag string) (*page, error) {
+		return g.getPage(ctx, c, url, etag)
+	})
+}
+
+// ID identifies the releases by their API URL.
+func (g *GiteaReleaser) ID() string {
+	return strings.TrimSuffix(g.BaseURL, "/") + "/api/v1/repos/" + g.Repo + "/releases"
+}
+
+// pageURL returns the releases API URL for the given page.
+func (g *GiteaReleaser) pageURL(n int) string {
+	q := url.Values{}
+	q.Set("page", strconv.Itoa(n))
+	q.Set("limit", strconv.Itoa(giteaPageSize))
+
+	return g.ID() + "?" + q.Encode()
+}
+
+// getPage gets a single page of releases. If etag is set, the request
+// is conditional.
+func (g *GiteaReleaser) getPage(ctx context.Context, c *http.Client, u, etag string) (*page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "token "+g.Token)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return &page{notModified: true}, nil
+	}
+
+	if err := rateLimited(resp, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting updates: %s", resp.Status)
+	}
+
+	// Gitea releases share their field names with GitHub.
+	p := page{etag: resp.Header.Get("Etag")}
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&p.releases); err != nil {
+		return nil, err
+	}
+
+	// Prefer the Link header, but not all versions send it. A full page
+	// means there may be more.
+	if link := nextLink(resp.Header.Get("Link")); link != "" {
+		p.next = resolveNext(req.URL, link)
+	} else if len(p.releases) == giteaPageSize {
+		n, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		p.next = g.pageURL(n + 1)
+	}
+
+	return &p, nil
+}
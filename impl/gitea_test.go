@@ -0,0 +1,212 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// giteaServer serves a full first page of releases and a partial
+// second page, without Link headers, in the style of the Gitea
+// releases API.
+func giteaServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/repo/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "token secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("limit") != "50" {
+			t.Errorf("wrong limit. got: %s", r.URL.Query().Get("limit"))
+		}
+
+		var rels []map[string]interface{}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			if r.Header.Get("If-None-Match") == `"gt-1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Etag", `"gt-1"`)
+
+			rels = append(rels,
+				map[string]interface{}{"tag_name": "v2.0.0-rc.1", "prerelease": true},
+				map[string]interface{}{"tag_name": "v1.50.0", "draft": true},
+			)
+			for i := 49; len(rels) < 50; i-- {
+				rels = append(rels, map[string]interface{}{
+					"tag_name": fmt.Sprintf("v1.%d.0", i),
+					"html_url": fmt.Sprintf("https://codeberg.example/owner/repo/releases/tag/v1.%d.0", i),
+					"assets": []map[string]interface{}{
+						{"name": "app.tar.gz", "size": 10, "browser_download_url": "https://example.com/app.tar.gz"},
+					},
+				})
+			}
+		case "2":
+			if r.Header.Get("If-None-Match") != "" {
+				t.Error("unexpected etag on page 2")
+			}
+			rels = append(rels, map[string]interface{}{"tag_name": "v0.1.0"})
+		default:
+			rels = []map[string]interface{}{}
+		}
+
+		_ = json.NewEncoder(w).Encode(rels)
+	}))
+}
+
+func TestGiteaReleaser(t *testing.T) {
+	srv := giteaServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gr := &impl.GiteaReleaser{
+		BaseURL:  srv.URL + "/",
+		Repo:     "owner/repo",
+		Token:    "secret-token",
+		Client:   srv.Client(),
+		MaxPages: 2,
+	}
+
+	rels, etag, err := gr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	if len(rels) != 51 {
+		t.Fatalf("wrong number of releases. expected: %d got: %d", 51, len(rels))
+	}
+
+	if etag != `"gt-1"` {
+		t.Errorf("wrong etag. expected: %s got: %s", `"gt-1"`, etag)
+	}
+
+	if !rels[0].Prerelease {
+		t.Error("expected prerelease")
+	}
+	if !rels[1].Draft {
+		t.Error("expected draft")
+	}
+
+	rel := rels[2]
+	if rel.TagName != "v1.49.0" || rel.HTMLURL != "https://codeberg.example/owner/repo/releases/tag/v1.49.0" {
+		t.Errorf("wrong release details. got: %+v", rel)
+	}
+	if len(rel.Assets) != 1 || rel.Assets[0].Size != 10 || rel.Assets[0].BrowserDownloadURL != "https://example.com/app.tar.gz" {
+		t.Errorf("wrong assets. got: %+v", rel.Assets)
+	}
+
+	if rels[50].TagName != "v0.1.0" {
+		t.Errorf("wrong tag name. expected: %s got: %s", "v0.1.0", rels[50].TagName)
+	}
+}
+
+func TestGiteaReleaser_maxPages(t *testing.T) {
+	srv := giteaServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gr := &impl.GiteaReleaser{
+		BaseURL:  srv.URL,
+		Repo:     "owner/repo",
+		Token:    "secret-token",
+		Client:   srv.Client(),
+		MaxPages: 1,
+	}
+
+	rels, _, err := gr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 50 {
+		t.Errorf("wrong number of releases. expected: %d got: %d", 50, len(rels))
+	}
+}
+
+func TestGiteaReleaser_followsLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("Link", `</api/v1/repos/owner/repo/releases?page=7&limit=50>; rel="next"`)
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+			return
+		}
+
+		if r.URL.Query().Get("page") != "7" {
+			t.Errorf("unexpected page. got: %s", r.URL.Query().Get("page"))
+		}
+		fmt.Fprint(w, `[{"tag_name": "v0.1.0"}]`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gr := &impl.GiteaReleaser{
+		BaseURL:  srv.URL,
+		Repo:     "owner/repo",
+		Client:   srv.Client(),
+		MaxPages: 2,
+	}
+
+	rels, _, err := gr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 2 {
+		t.Errorf("wrong number of releases. expected: %d got: %d", 2, len(rels))
+	}
+}
+
+func TestGiteaReleaser_supportsEtag(t *testing.T) {
+	srv := giteaServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gr := &impl.GiteaReleaser{
+		BaseURL: srv.URL,
+		Repo:    "owner/repo",
+		Token:   "secret-token",
+		Client:  srv.Client(),
+	}
+
+	etag := `"gt-1"`
+	rels, outEtag, err := gr.Get(ctx, etag)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(rels) != 0 {
+		t.Error("expected no rels but got some")
+	}
+	if outEtag != etag {
+		t.Error("incorrect etag. wanted:", etag, "got:", outEtag)
+	}
+}
+
+func TestGiteaReleaser_errorOnBadToken(t *testing.T) {
+	srv := giteaServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gr := &impl.GiteaReleaser{
+		BaseURL: srv.URL,
+		Repo:    "owner/repo",
+		Client:  srv.Client(),
+	}
+
+	_, _, err := gr.Get(ctx, "")
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+}
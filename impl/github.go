@@ -9,50 +9,154 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// DefaultMaxPages is the number of pages of releases fetched by the
+// paginated Releasers in this package if MaxPages is not set. They
+// request large pages, so further pages are only fetched for the longest
+// release lists.
+const DefaultMaxPages = 3
+
+// gitHubPageSize is the number of releases requested per page. It is the
+// maximum GitHub allows.
+const gitHubPageSize = 100
+
+// DefaultMaxHistoryPages is the number of pages of releases fetched for
+// release history if MaxHistoryPages is not set.
+const DefaultMaxHistoryPages = 10
+
 // GitHubReleaser is the default Releaser used in whatsnew.
 type GitHubReleaser struct {
 	URL    string       // a complete URL to the releases API.
+	Token  string       // optional. a token to access private repositories and raise rate limits.
 	Client *http.Client // if not set, http.DefaultClient is used.
+
+	// MaxPages limits how many pages of releases are fetched, following
+	// the Link header. If not set, DefaultMaxPages is used.
+	MaxPages int
+
+	// MaxHistoryPages limits how many pages of releases are fetched for
+	// History. If not set, DefaultMaxHistoryPages is used.
+	MaxHistoryPages int
 }
 
 // Get a list of releases.
+//
+// Releases are fetched page by page, until there are no more pages, or
+// MaxPages is reached. The etag only applies to the first page; if it
+// has not changed, no further pages are fetched.
 func (g *GitHubReleaser) Get(ctx context.Context, etag string) ([]Release, string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.URL, nil)
-	if err != nil {
-		return nil, "", err
+	c := g.Client
+	if c == nil {
+		c = http.DefaultClient
 	}
 
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	if etag != "" {
-		req.Header.Set("If-None-Match", etag)
+	maxPages := g.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
 	}
 
+	return getPages(ctx, withPageSize(g.URL, "per_page", gitHubPageSize), etag, maxPages, func(ctx context.Context, url, etag string) (*page, error) {
+		return g.getPage(ctx, c, url, etag)
+	})
+}
+
+// History gets releases page by page, following the Link header, until
+// done reports that enough have been fetched, there are no more pages,
+// or MaxHistoryPages is reached.
+func (g *GitHubReleaser) History(ctx context.Context, done func([]Release) bool) ([]Release, error) {
 	c := g.Client
 	if c == nil {
 		c = http.DefaultClient
 	}
 
+	maxPages := g.MaxHistoryPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxHistoryPages
+	}
+
+	return getHistory(ctx, withPageSize(g.URL, "per_page", gitHubPageSize), maxPages, func(ctx context.Context, url, etag string) (*page, error) {
+		return g.getPage(ctx, c, url, etag)
+	}, done)
+}
+
+// ID identifies the releases by their API URL.
+func (g *GitHubReleaser) ID() string {
+	return g.URL
+}
+
+// getPage gets a single page of releases. If etag is set, the request
+// is conditional.
+func (g *GitHubReleaser) getPage(ctx context.Context, c *http.Client, url, etag string) (*page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if etag != "" && resp.StatusCode == http.StatusNotModified {
-		return nil, etag, nil // this will fall back to existing stuff.
+		return &page{notModified: true}, nil
+	}
+
+	if err := rateLimited(resp, time.Now()); err != nil {
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("error getting updates: %s", resp.Status)
+		return nil, fmt.Errorf("error getting updates: %s", resp.Status)
 	}
 
-	var rels []Release
+	p := page{etag: resp.Header.Get("Etag")}
 	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&rels); err != nil {
-		return nil, "", err
+	if err := dec.Decode(&p.releases); err != nil {
+		return nil, err
+	}
+
+	if link := nextLink(resp.Header.Get("Link")); link != "" {
+		p.next = resolveNext(req.URL, link)
+	}
+
+	return &p, nil
+}
+
+// nextLink returns the URL with rel="next" from an RFC 8288 Link header,
+// as used by GitHub for pagination.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if len(target) < 2 || target[0] != '<' || target[len(target)-1] != '>' {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "rel=") {
+				continue
+			}
+
+			for _, rel := range strings.Fields(strings.Trim(param[len("rel="):], `"`)) {
+				if rel == "next" {
+					return target[1 : len(target)-1]
+				}
+			}
+		}
 	}
 
-	return rels, resp.Header.Get("Etag"), nil
+	return ""
 }
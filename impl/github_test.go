@@ -7,8 +7,12 @@ package impl_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jbowes/whatsnew/impl"
 )
@@ -40,6 +44,54 @@ func TestGihubReleaser(t *testing.T) {
 	}
 }
 
+func TestGihubReleaser_releaseDetails(t *testing.T) {
+	ctx := context.Background()
+	ghr := &impl.GitHubReleaser{
+		URL: "http://github.com/repos/you/your-app/releases",
+		Client: &http.Client{
+			Transport: http.NewFileTransport(
+				http.Dir("../testdata/example"),
+			),
+		},
+	}
+	rels, _, err := ghr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	rel := rels[0]
+	if rel.Name != "Thirty" {
+		t.Errorf("wrong name. expected: %s got: %s", "Thirty", rel.Name)
+	}
+	if rel.Body != "Lots of *new* things." {
+		t.Errorf("wrong body. expected: %s got: %s", "Lots of *new* things.", rel.Body)
+	}
+
+	url := "https://github.com/you/your-app/releases/tag/0.30.0"
+	if rel.HTMLURL != url {
+		t.Errorf("wrong url. expected: %s got: %s", url, rel.HTMLURL)
+	}
+
+	published := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	if !rel.PublishedAt.Equal(published) {
+		t.Errorf("wrong published at. expected: %s got: %s", published, rel.PublishedAt)
+	}
+
+	if len(rel.Assets) != 1 {
+		t.Fatalf("wrong number of assets. expected: %d got: %d", 1, len(rel.Assets))
+	}
+
+	want := impl.Asset{
+		Name:               "your-app_linux_amd64.tar.gz",
+		Size:               1024,
+		BrowserDownloadURL: "https://github.com/you/your-app/releases/download/0.30.0/your-app_linux_amd64.tar.gz",
+		ContentType:        "application/gzip",
+	}
+	if rel.Assets[0] != want {
+		t.Errorf("wrong asset. expected: %+v got: %+v", want, rel.Assets[0])
+	}
+}
+
 func TestGihubReleaser_errorOn404(t *testing.T) {
 	ctx := context.Background()
 	ghr := &impl.GitHubReleaser{
@@ -136,3 +188,384 @@ func TestGihubReleaser_supportsEtag(t *testing.T) {
 		t.Error("incorrect etag. wanted:", etag, "got:", outEtag)
 	}
 }
+
+// pagedServer serves three pages of releases, linked via the Link header.
+// Only the first page has an etag.
+func pagedServer(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+
+		if page != "1" && r.Header.Get("If-None-Match") != "" {
+			t.Errorf("unexpected etag on page %s", page)
+		}
+
+		switch page {
+		case "1":
+			if r.Header.Get("If-None-Match") == `"page-1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Etag", `"page-1"`)
+			w.Header().Set("Link", fmt.Sprintf(`<%s/releases?page=2>; rel="next", <%s/releases?page=3>; rel="last"`, srv.URL, srv.URL))
+		case "2":
+			w.Header().Set("Etag", `"page-2"`)
+			w.Header().Set("Link", `</releases?page=3>; rel="next"`) // relative links are resolved
+		case "3":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `[{"tag_name": "v%s.0.0"}]`, page)
+	}))
+
+	return srv
+}
+
+func TestGihubReleaser_pagination(t *testing.T) {
+	srv := pagedServer(t)
+	defer srv.Close()
+
+	tcs := map[string]struct {
+		maxPages int
+		tags     []string
+	}{
+		"default":   {maxPages: 0, tags: []string{"v1.0.0", "v2.0.0", "v3.0.0"}},
+		"one page":  {maxPages: 1, tags: []string{"v1.0.0"}},
+		"two pages": {maxPages: 2, tags: []string{"v1.0.0", "v2.0.0"}},
+		"past end":  {maxPages: 10, tags: []string{"v1.0.0", "v2.0.0", "v3.0.0"}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ghr := &impl.GitHubReleaser{
+				URL:      srv.URL + "/releases",
+				Client:   srv.Client(),
+				MaxPages: tc.maxPages,
+			}
+
+			rels, etag, err := ghr.Get(ctx, "")
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err)
+			}
+
+			if len(rels) != len(tc.tags) {
+				t.Fatalf("wrong number of releases. expected: %d got: %d", len(tc.tags), len(rels))
+			}
+			for i, tag := range tc.tags {
+				if rels[i].TagName != tag {
+					t.Errorf("wrong tag name. expected: %s got: %s", tag, rels[i].TagName)
+				}
+			}
+
+			if etag != `"page-1"` {
+				t.Errorf("wrong etag. expected: %s got: %s", `"page-1"`, etag)
+			}
+		})
+	}
+}
+
+func TestGihubReleaser_history(t *testing.T) {
+	srv := pagedServer(t)
+	defer srv.Close()
+
+	tcs := map[string]struct {
+		maxPages int
+		until    string
+		tags     []string
+	}{
+		"until found":   {until: "v2.0.0", tags: []string{"v1.0.0", "v2.0.0"}},
+		"never found":   {until: "v9.0.0", tags: []string{"v1.0.0", "v2.0.0", "v3.0.0"}},
+		"max pages":     {maxPages: 1, until: "v2.0.0", tags: []string{"v1.0.0"}},
+		"on first page": {until: "v1.0.0", tags: []string{"v1.0.0"}},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ghr := &impl.GitHubReleaser{
+				URL:             srv.URL + "/releases",
+				Client:          srv.Client(),
+				MaxPages:        1,
+				MaxHistoryPages: tc.maxPages,
+			}
+
+			rels, err := ghr.History(ctx, func(rels []impl.Release) bool {
+				for _, rel := range rels {
+					if rel.TagName == tc.until {
+						return true
+					}
+				}
+				return false
+			})
+			if err != nil {
+				t.Fatalf("got unexpected error: %s", err)
+			}
+
+			if len(rels) != len(tc.tags) {
+				t.Fatalf("wrong number of releases. expected: %d got: %d", len(tc.tags), len(rels))
+			}
+			for i, tag := range tc.tags {
+				if rels[i].TagName != tag {
+					t.Errorf("wrong tag name. expected: %s got: %s", tag, rels[i].TagName)
+				}
+			}
+		})
+	}
+}
+
+func TestGihubReleaser_paginationNotModified(t *testing.T) {
+	srv := pagedServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	ghr := &impl.GitHubReleaser{
+		URL:    srv.URL + "/releases",
+		Client: srv.Client(),
+	}
+
+	rels, etag, err := ghr.Get(ctx, `"page-1"`)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 0 {
+		t.Error("expected no rels but got some")
+	}
+	if etag != `"page-1"` {
+		t.Errorf("wrong etag. expected: %s got: %s", `"page-1"`, etag)
+	}
+}
+
+func TestGihubReleaser_paginationErrorOnLaterPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Link", `</releases?page=2>; rel="next"`)
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	ghr := &impl.GitHubReleaser{
+		URL:      srv.URL + "/releases",
+		Client:   srv.Client(),
+		MaxPages: 2,
+	}
+
+	_, _, err := ghr.Get(ctx, "")
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+}
+
+func TestGihubReleaser_linkToOtherHost(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to other host. authorization: %q", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `[{"tag_name": "v0.1.0"}]`)
+	}))
+	defer other.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/releases?page=2>; rel="next"`, other.URL))
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	ghr := &impl.GitHubReleaser{
+		URL:      srv.URL + "/releases",
+		Token:    "secret-token",
+		Client:   srv.Client(),
+		MaxPages: 2,
+	}
+
+	rels, _, err := ghr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 1 {
+		t.Errorf("wrong number of releases. expected: %d got: %d", 1, len(rels))
+	}
+}
+
+func TestGihubReleaser_sendsToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	ghr := &impl.GitHubReleaser{
+		URL:    srv.URL + "/releases",
+		Token:  "secret-token",
+		Client: srv.Client(),
+	}
+
+	rels, _, err := ghr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 1 {
+		t.Errorf("wrong number of releases. expected: %d got: %d", 1, len(rels))
+	}
+
+	ghr.Token = "wrong-token"
+	_, _, err = ghr.Get(ctx, "")
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if strings.Contains(err.Error(), "wrong-token") {
+		t.Errorf("token leaked in error: %s", err)
+	}
+}
+
+func TestGihubReleaser_rateLimit(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	date := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+
+	tcs := map[string]struct {
+		status  int
+		headers map[string]string
+		limited bool
+		after   time.Time // zero means roughly now plus the given delay
+		delay   time.Duration
+	}{
+		"primary limit": {
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprint(reset.Unix()),
+			},
+			limited: true,
+			after:   reset,
+		},
+		"retry after seconds": {
+			status:  http.StatusForbidden,
+			headers: map[string]string{"Retry-After": "120"},
+			limited: true,
+			delay:   2 * time.Minute,
+		},
+		"retry after date": {
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"Retry-After": date.UTC().Format(http.TimeFormat)},
+			limited: true,
+			after:   date,
+		},
+		"retry after preferred": {
+			status: http.StatusTooManyRequests,
+			headers: map[string]string{
+				"Retry-After":           "60",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprint(reset.Unix()),
+			},
+			limited: true,
+			delay:   time.Minute,
+		},
+		"past reset ignored": {
+			status: http.StatusForbidden,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprint(time.Now().Add(-time.Hour).Unix()),
+			},
+			limited: true,
+			delay:   time.Minute,
+		},
+		"past retry after date ignored": {
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"Retry-After": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+			limited: true,
+			delay:   time.Minute,
+		},
+		"too many requests without headers": {
+			status:  http.StatusTooManyRequests,
+			limited: true,
+			delay:   time.Minute,
+		},
+		"forbidden is not a rate limit": {
+			status:  http.StatusForbidden,
+			headers: map[string]string{"X-RateLimit-Remaining": "42"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tc.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			ghr := &impl.GitHubReleaser{URL: srv.URL, Client: srv.Client()}
+
+			start := time.Now()
+			_, _, err := ghr.Get(ctx, "")
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+
+			var rle *impl.RateLimitError
+			if !errors.As(err, &rle) {
+				if tc.limited {
+					t.Fatalf("expected rate limit error. got: %s", err)
+				}
+				return
+			}
+			if !tc.limited {
+				t.Fatalf("unexpected rate limit error: %s", err)
+			}
+
+			if !tc.after.IsZero() && !rle.RetryAfter.Equal(tc.after) {
+				t.Errorf("wrong retry after. expected: %s got: %s", tc.after, rle.RetryAfter)
+			}
+			if tc.after.IsZero() && (rle.RetryAfter.Before(start.Add(tc.delay)) || rle.RetryAfter.After(time.Now().Add(tc.delay))) {
+				t.Errorf("wrong retry after. expected about: %s got: %s", start.Add(tc.delay), rle.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestGihubReleaser_pageSize(t *testing.T) {
+	tcs := map[string]struct {
+		url  string
+		size string
+	}{
+		"default": {url: "/releases", size: "100"},
+		"kept":    {url: "/releases?per_page=10", size: "10"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var size string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				size = r.URL.Query().Get("per_page")
+				fmt.Fprint(w, `[]`)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			ghr := &impl.GitHubReleaser{URL: srv.URL + tc.url, Client: srv.Client()}
+			if _, _, err := ghr.Get(ctx, ""); err != nil {
+				t.Fatalf("got unexpected error: %s", err)
+			}
+
+			if size != tc.size {
+				t.Errorf("wrong page size. expected: %s got: %s", tc.size, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultGitLabURL is the base URL used by a GitLabReleaser if BaseURL
+// is not set.
+const DefaultGitLabURL = "https://gitlab.com"
+
+// gitLabPageSize is the number of releases requested per page. It is the
+// maximum GitLab allows.
+const gitLabPageSize = 100
+
+// GitLabReleaser is a Releaser for the GitLab releases API, for both
+// gitlab.com and self-managed instances.
+type GitLabReleaser struct {
+	BaseURL string       // the base URL of the GitLab instance. if not set, DefaultGitLabURL is used.
+	Project string       // the project path, eg `group/project`, or numeric project ID.
+	Token   string       // optional. a private, project, or personal access token.
+	Client  *http.Client // if not set, http.DefaultClient is used.
+
+	// MaxPages limits how many pages of releases are fetched, following
+	// the X-Next-Page header. If not set, DefaultMaxPages is used.
+	MaxPages int
+}
+
+// gitLabRelease is a release as returned by the GitLab releases API.
+type gitLabRelease struct {
+	TagName         string    `json:"tag_name"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	ReleasedAt      time.Time `json:"released_at"`
+	UpcomingRelease bool      `json:"upcoming_release"`
+	Links           struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+	Assets struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+func (r *gitLabRelease) release() Release {
+	rel := Release{
+		Prerelease:  r.UpcomingRelease,
+		TagName:     r.TagName,
+		Name:        r.Name,
+		Body:        r.Description,
+		HTMLURL:     r.Links.Self,
+		PublishedAt: r.ReleasedAt,
+	}
+
+	for _, l := range r.Assets.Links {
+		a := Asset{Name: l.Name, BrowserDownloadURL: l.DirectAssetURL}
+		if a.BrowserDownloadURL == "" {
+			a.BrowserDownloadURL = l.URL
+		}
+		rel.Assets = append(rel.Assets, a)
+	}
+
+	return rel
+}
+
+// Get a list of releases.
+//
+// Releases are fetched page by page, until there are no more pages, or
+// MaxPages is reached. The etag only applies to the first page; if it
+// has not changed, no further pages are fetched.
+func (g *GitLabReleaser) Get(ctx context.Context, etag string) ([]Release, string, error) {
+	c := g.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	maxPages := g.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	return getPages(ctx, withPageSize(g.url(), "per_page", gitLabPageSize), etag, maxPages, func(ctx context.Context, url, etag string) (*page, error) {
+		return g.getPage(ctx, c, url, etag)
+	})
+}
+
+// ID identifies the releases by their API URL.
+func (g *GitLabReleaser) ID() string {
+	return g.url()
+}
+
+// url returns the releases API URL for the project.
+func (g *GitLabReleaser) url() string {
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultGitLabURL
+	}
+
+	return strings.TrimSuffix(base, "/") + "/api/v4/projects/" + url.PathEscape(g.Project) + "/releases"
+}
+
+// getPage gets a single page of releases. If etag is set, the request
+// is conditional.
+func (g *GitLabReleaser) getPage(ctx context.Context, c *http.Client, u, etag string) (*page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if g.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", g.Token)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return &page{notModified: true}, nil
+	}
+
+	if err := rateLimited(resp, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting updates: %s", resp.Status)
+	}
+
+	var glRels []gitLabRelease
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&glRels); err != nil {
+		return nil, err
+	}
+
+	p := page{etag: resp.Header.Get("Etag")}
+	for i := range glRels {
+		p.releases = append(p.releases, glRels[i].release())
+	}
+
+	if next := resp.Header.Get("X-Next-Page"); next != "" {
+		nu := *req.URL
+		q := nu.Query()
+		q.Set("page", next)
+		nu.RawQuery = q.Encode()
+		p.next = nu.String()
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// gitLabServer serves two pages of releases for the `group/sub/project`
+// project, in the style of the GitLab releases API.
+func gitLabServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsub%2Fproject/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("PRIVATE-TOKEN") != "secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("per_page") != "100" {
+			t.Errorf("wrong page size. got: %s", r.URL.Query().Get("per_page"))
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			if r.Header.Get("If-None-Match") == `"gl-1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("Etag", `"gl-1"`)
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[
+				{
+					"tag_name": "v2.0.0",
+					"name": "Two",
+					"description": "Coming soon.",
+					"released_at": "2021-05-01T12:00:00Z",
+					"upcoming_release": true,
+					"_links": {"self": "https://gitlab.example.com/group/sub/project/-/releases/v2.0.0"},
+					"assets": {
+						"links": [
+							{"name": "linked", "url": "https://example.com/linked"},
+							{"name": "direct", "url": "https://example.com/direct", "direct_asset_url": "https://example.com/direct/download"}
+						]
+					}
+				}
+			]`)
+		case "2":
+			if r.Header.Get("If-None-Match") != "" {
+				t.Error("unexpected etag on page 2")
+			}
+
+			w.Header().Set("X-Next-Page", "")
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "released_at": "2021-04-01T12:00:00Z"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGitLabReleaser(t *testing.T) {
+	srv := gitLabServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	glr := &impl.GitLabReleaser{
+		BaseURL:  srv.URL + "/",
+		Project:  "group/sub/project",
+		Token:    "secret-token",
+		Client:   srv.Client(),
+		MaxPages: 2,
+	}
+
+	rels, etag, err := glr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	if len(rels) != 2 {
+		t.Fatalf("wrong number of releases. expected: %d got: %d", 2, len(rels))
+	}
+
+	if etag != `"gl-1"` {
+		t.Errorf("wrong etag. expected: %s got: %s", `"gl-1"`, etag)
+	}
+
+	rel := rels[0]
+	if rel.TagName != "v2.0.0" || rel.Name != "Two" || rel.Body != "Coming soon." {
+		t.Errorf("wrong release details. got: %+v", rel)
+	}
+	if !rel.Prerelease {
+		t.Error("expected upcoming release to be a prerelease")
+	}
+	if rel.HTMLURL != "https://gitlab.example.com/group/sub/project/-/releases/v2.0.0" {
+		t.Errorf("wrong url. got: %s", rel.HTMLURL)
+	}
+	if published := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC); !rel.PublishedAt.Equal(published) {
+		t.Errorf("wrong published at. expected: %s got: %s", published, rel.PublishedAt)
+	}
+
+	if len(rel.Assets) != 2 {
+		t.Fatalf("wrong number of assets. expected: %d got: %d", 2, len(rel.Assets))
+	}
+	if rel.Assets[0].BrowserDownloadURL != "https://example.com/linked" {
+		t.Errorf("wrong asset url. got: %s", rel.Assets[0].BrowserDownloadURL)
+	}
+	if rel.Assets[1].BrowserDownloadURL != "https://example.com/direct/download" {
+		t.Errorf("wrong asset url. got: %s", rel.Assets[1].BrowserDownloadURL)
+	}
+
+	if rels[1].TagName != "v1.0.0" || rels[1].Prerelease {
+		t.Errorf("wrong release details. got: %+v", rels[1])
+	}
+}
+
+func TestGitLabReleaser_maxPages(t *testing.T) {
+	srv := gitLabServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	glr := &impl.GitLabReleaser{
+		BaseURL:  srv.URL,
+		Project:  "group/sub/project",
+		Token:    "secret-token",
+		Client:   srv.Client(),
+		MaxPages: 1,
+	}
+
+	rels, _, err := glr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 1 {
+		t.Errorf("wrong number of releases. expected: %d got: %d", 1, len(rels))
+	}
+}
+
+func TestGitLabReleaser_supportsEtag(t *testing.T) {
+	srv := gitLabServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	glr := &impl.GitLabReleaser{
+		BaseURL: srv.URL,
+		Project: "group/sub/project",
+		Token:   "secret-token",
+		Client:  srv.Client(),
+	}
+
+	etag := `"gl-1"`
+	rels, outEtag, err := glr.Get(ctx, etag)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(rels) != 0 {
+		t.Error("expected no rels but got some")
+	}
+	if outEtag != etag {
+		t.Error("incorrect etag. wanted:", etag, "got:", outEtag)
+	}
+}
+
+func TestGitLabReleaser_errorOnBadToken(t *testing.T) {
+	srv := gitLabServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	glr := &impl.GitLabReleaser{
+		BaseURL: srv.URL,
+		Project: "group/sub/project",
+		Token:   "wrong-token",
+		Client:  srv.Client(),
+	}
+
+	_, _, err := glr.Get(ctx, "")
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if strings.Contains(err.Error(), "wrong-token") {
+		t.Errorf("token leaked in error: %s", err)
+	}
+}
+
+func TestGitLabReleaser_errorOnBadJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "This: is yaml")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	glr := &impl.GitLabReleaser{
+		BaseURL: srv.URL,
+		Project: "1234",
+		Client:  srv.Client(),
+	}
+
+	_, _, err := glr.Get(ctx, "")
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+}
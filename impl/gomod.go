@@ -0,0 +1,104 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// parseRetractions parses the retract directives from a go.mod file.
+//
+// The rationale for a retraction is taken from the comments directly
+// before it, or at the end of its line. Inside a retract block, entries
+// without their own comments use the block's comments.
+func parseRetractions(r io.Reader) ([]Retraction, error) {
+	var rets []Retraction
+	var comments []string // comment lines directly before the current line.
+	inBlock := false
+	blockRationale := ""
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, suffix := splitComment(sc.Text())
+		if line == "" {
+			if suffix != "" {
+				comments = append(comments, suffix)
+			} else {
+				comments = nil // a blank line separates comments from directives.
+			}
+			continue
+		}
+
+		if suffix != "" {
+			comments = append(comments, suffix)
+		}
+		rationale := strings.Join(comments, "\n")
+		comments = nil
+
+		fields := strings.Fields(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock:
+			if rationale == "" {
+				rationale = blockRationale
+			}
+			if ret, ok := parseRetraction(line, rationale); ok {
+				rets = append(rets, ret)
+			}
+		case fields[0] != "retract":
+		case len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			blockRationale = rationale
+		default:
+			if ret, ok := parseRetraction(strings.TrimSpace(line[len("retract"):]), rationale); ok {
+				rets = append(rets, ret)
+			}
+		}
+	}
+
+	return rets, sc.Err()
+}
+
+// splitComment splits a go.mod line into its trimmed content and the
+// text of any trailing `//` comment.
+func splitComment(line string) (string, string) {
+	i := strings.Index(line, "//")
+	if i < 0 {
+		return strings.TrimSpace(line), ""
+	}
+
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+2:])
+}
+
+// parseRetraction parses a single version, or a `[low, high]` range.
+func parseRetraction(s, rationale string) (Retraction, bool) {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		parts := strings.Split(s[1:len(s)-1], ",")
+		if len(parts) != 2 {
+			return Retraction{}, false
+		}
+
+		low, high := unquote(parts[0]), unquote(parts[1])
+		if low == "" || high == "" {
+			return Retraction{}, false
+		}
+
+		return Retraction{Low: low, High: high, Rationale: rationale}, true
+	}
+
+	v := unquote(s)
+	if v == "" || strings.ContainsAny(v, " \t") {
+		return Retraction{}, false
+	}
+
+	return Retraction{Low: v, High: v, Rationale: rationale}, true
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"`)
+}
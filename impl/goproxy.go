@@ -0,0 +1,413 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbowes/semver"
+)
+
+// DefaultGoProxy is the proxy list used by a GoProxyReleaser if neither
+// Proxy nor the GOPROXY environment variable are set.
+const DefaultGoProxy = "https://proxy.golang.org,direct"
+
+// GoProxyReleaser is a Releaser for Go modules, using the module proxy
+// protocol. Every version known to the proxy is returned as a Release.
+//
+// Proxy and NoProxy follow the semantics of the go command's GOPROXY and
+// GONOPROXY settings. Only the environment is consulted, not `go env -w`
+// settings. As a GoProxyReleaser does not talk to version control systems,
+// `direct` entries are ignored.
+type GoProxyReleaser struct {
+	Module string       // the module path, eg `github.com/jbowes/whatsnew`
+	Client *http.Client // if not set, http.DefaultClient is used.
+
+	// Proxy is a GOPROXY style list of proxy URLs. If not set, the
+	// GOPROXY environment variable is used, then DefaultGoProxy.
+	Proxy string
+
+	// NoProxy is a GONOPROXY style list of module path prefix patterns
+	// that are not fetched through a proxy. If not set, the GONOPROXY
+	// environment variable is used, then GOPRIVATE.
+	NoProxy string
+
+	mu       sync.Mutex
+	versions []string // the version list from the last Get, if any.
+}
+
+// errNotFound is returned for 404 and 410 responses from a proxy, which
+// allow falling back to the next proxy in the list.
+var errNotFound = errors.New("not found")
+
+// proxyEntry is a single proxy from a GOPROXY list.
+type proxyEntry struct {
+	url         string
+	fallBackAny bool // fall back on any error, not just errNotFound.
+}
+
+// goProxyInfo is the JSON form of the @latest and .info endpoints.
+type goProxyInfo struct {
+	Version string
+	Time    time.Time
+}
+
+// Get a list of releases.
+//
+// Each proxy is tried in order, falling back according to the list's
+// separators. The etag applies to the version list.
+func (g *GoProxyReleaser) Get(ctx context.Context, etag string) ([]Release, string, error) {
+	var rels []Release
+	newEtag := ""
+	err := g.eachProxy(func(proxy string) error {
+		var err error
+		rels, newEtag, err = g.getFrom(ctx, proxy, etag)
+		return err
+	})
+
+	return rels, newEtag, err
+}
+
+// ID identifies the releases by their module path.
+func (g *GoProxyReleaser) ID() string {
+	return g.Module
+}
+
+// eachProxy calls fn with each configured proxy, until one succeeds,
+// or fails in a way that does not allow falling back.
+func (g *GoProxyReleaser) eachProxy(fn func(proxy string) error) error {
+	noProxy := g.NoProxy
+	if noProxy == "" {
+		noProxy = os.Getenv("GONOPROXY")
+	}
+	if noProxy == "" {
+		noProxy = os.Getenv("GOPRIVATE")
+	}
+	if matchPrefixPatterns(noProxy, g.Module) {
+		return fmt.Errorf("module %s is not fetched through a proxy", g.Module)
+	}
+
+	proxy := g.Proxy
+	if proxy == "" {
+		proxy = os.Getenv("GOPROXY")
+	}
+	if proxy == "" {
+		proxy = DefaultGoProxy
+	}
+
+	err := errors.New("no usable proxy in GOPROXY")
+	for _, p := range parseProxyList(proxy) {
+		switch p.url {
+		case "off":
+			return errors.New("module lookup disabled by GOPROXY=off")
+		case "direct":
+			continue
+		}
+
+		err = fn(p.url)
+		if err == nil || !p.fallBackAny && !errors.Is(err, errNotFound) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// getFrom gets the list of releases from a single proxy.
+func (g *GoProxyReleaser) getFrom(ctx context.Context, proxy, etag string) ([]Release, string, error) {
+	base := strings.TrimSuffix(proxy, "/") + "/" + escapePath(g.Module)
+
+	resp, err := g.get(ctx, base+"/@v/list", etag)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil // this will fall back to existing stuff.
+	}
+
+	if err := checkProxyStatus(resp); err != nil {
+		return nil, "", err
+	}
+
+	versions, err := parseVersionList(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	g.mu.Lock()
+	g.versions = versions
+	g.mu.Unlock()
+
+	rels := []Release{}
+	seen := map[string]bool{}
+	for _, v := range versions {
+		seen[v] = true
+		rels = append(rels, Release{TagName: v})
+	}
+
+	// @latest may know of a version not in the list, such as a
+	// pseudo-version, and knows its publish time.
+	var latest goProxyInfo
+	if err := g.getJSON(ctx, base+"/@latest", &latest); err != nil && !errors.Is(err, errNotFound) {
+		return nil, "", err
+	}
+
+	if latest.Version != "" {
+		if !seen[latest.Version] {
+			rels = append(rels, Release{TagName: latest.Version})
+		}
+
+		for i := range rels {
+			if rels[i].TagName == latest.Version {
+				rels[i].PublishedAt = latest.Time
+			}
+		}
+	}
+
+	return rels, resp.Header.Get("Etag"), nil
+}
+
+// Retractions gets the versions retracted in the go.mod file of the
+// latest version of the module, ignoring retractions, as the go command
+// does. That is the highest release in the version list from the last
+// Get, or from the proxy if Get has not been called. A release may
+// retract itself, so the proxy's `@latest`, which excludes retracted
+// versions, is only used if the list is empty.
+func (g *GoProxyReleaser) Retractions(ctx context.Context) ([]Retraction, error) {
+	var rets []Retraction
+	err := g.eachProxy(func(proxy string) error {
+		var err error
+		rets, err = g.retractionsFrom(ctx, proxy)
+		return err
+	})
+
+	return rets, err
+}
+
+func (g *GoProxyReleaser) retractionsFrom(ctx context.Context, proxy string) ([]Retraction, error) {
+	base := strings.TrimSuffix(proxy, "/") + "/" + escapePath(g.Module)
+
+	g.mu.Lock()
+	versions := g.versions
+	g.mu.Unlock()
+
+	if versions == nil {
+		var err error
+		if versions, err = g.getVersions(ctx, base); err != nil {
+			return nil, err
+		}
+	}
+
+	latest := highest(versions)
+	if latest == "" {
+		var info goProxyInfo
+		if err := g.getJSON(ctx, base+"/@latest", &info); err != nil {
+			return nil, err
+		}
+		latest = info.Version
+	}
+
+	resp, err := g.get(ctx, base+"/@v/"+escapePath(latest)+".mod", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkProxyStatus(resp); err != nil {
+		return nil, err
+	}
+
+	return parseRetractions(resp.Body)
+}
+
+// getVersions gets the version list from the proxy at base.
+func (g *GoProxyReleaser) getVersions(ctx context.Context, base string) ([]string, error) {
+	resp, err := g.get(ctx, base+"/@v/list", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkProxyStatus(resp); err != nil {
+		return nil, err
+	}
+
+	return parseVersionList(resp.Body)
+}
+
+// parseVersionList parses a proxy version list, one version per line,
+// dropping blank lines and duplicates.
+func parseVersionList(r io.Reader) ([]string, error) {
+	versions := []string{}
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		v := strings.TrimSpace(sc.Text())
+		if v == "" || seen[v] {
+			continue
+		}
+
+		seen[v] = true
+		versions = append(versions, v)
+	}
+
+	return versions, sc.Err()
+}
+
+// highest returns the highest release in versions, or the highest
+// prerelease if there are no releases, or the empty string if there are
+// neither.
+func highest(versions []string) string {
+	var best, bestPre string
+	var bestV, bestPreV *semver.Version
+	for _, v := range versions {
+		sv, err := semver.Parse(strings.TrimPrefix(v, "v"))
+		switch {
+		case err != nil:
+		case sv.Prerelease() == "":
+			if bestV.Compare(sv) < 0 {
+				best, bestV = v, sv
+			}
+		case bestPreV.Compare(sv) < 0:
+			bestPre, bestPreV = v, sv
+		}
+	}
+
+	if best == "" {
+		return bestPre
+	}
+
+	return best
+}
+
+func (g *GoProxyReleaser) get(ctx context.Context, url, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	c := g.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	return c.Do(req)
+}
+
+func (g *GoProxyReleaser) getJSON(ctx context.Context, url string, v interface{}) error {
+	resp, err := g.get(ctx, url, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkProxyStatus(resp); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	return dec.Decode(v)
+}
+
+// checkProxyStatus returns an error for unsuccessful responses. Not found
+// responses wrap errNotFound.
+func checkProxyStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusGone:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("error getting updates: %s: %w", resp.Status, errNotFound)
+	default:
+		return fmt.Errorf("error getting updates: %s", resp.Status)
+	}
+}
+
+// parseProxyList parses a GOPROXY style list. Entries separated by a
+// comma only fall back on not found errors. Entries separated by a pipe
+// fall back on any error.
+func parseProxyList(list string) []proxyEntry {
+	var entries []proxyEntry
+	for list != "" {
+		i := strings.IndexAny(list, ",|")
+		e := proxyEntry{url: list}
+		list = ""
+		if i >= 0 {
+			e.url, e.fallBackAny, list = e.url[:i], e.url[i] == '|', e.url[i+1:]
+		}
+
+		if e.url = strings.TrimSpace(e.url); e.url != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// matchPrefixPatterns reports whether any path prefix of target matches
+// one of the comma separated glob patterns, as in GONOPROXY.
+func matchPrefixPatterns(globs, target string) bool {
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSuffix(strings.TrimSpace(glob), "/")
+		if glob == "" {
+			continue
+		}
+
+		// Truncate target to the same number of path elements as glob.
+		n := strings.Count(glob, "/")
+		prefix := target
+		for i := 0; i < len(target); i++ {
+			if target[i] == '/' {
+				if n == 0 {
+					prefix = target[:i]
+					break
+				}
+				n--
+			}
+		}
+		if n > 0 {
+			continue // target has fewer path elements than glob.
+		}
+
+		if ok, _ := path.Match(glob, prefix); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapePath escapes a module path or version for use in proxy URLs,
+// replacing uppercase letters with an exclamation mark followed by the
+// lowercase letter.
+func escapePath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
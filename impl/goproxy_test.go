@@ -0,0 +1,375 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
+)
+
+// goProxyServer serves versions of `github.com/You/your-app` in the style
+// of a Go module proxy.
+func goProxyServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/!you/your-app/@v/list":
+			if r.Header.Get("If-None-Match") == `"list-1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Etag", `"list-1"`)
+			fmt.Fprint(w, "v1.0.0\nv1.1.0\n\nv1.1.0\nv1.2.0-rc.1\n")
+		case "/github.com/!you/your-app/@latest":
+			fmt.Fprint(w, `{"Version": "v1.1.0", "Time": "2021-04-01T12:00:00Z"}`)
+		case "/github.com/!you/your-app/@v/v1.1.0.mod":
+			fmt.Fprint(w, goMod)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+const goMod = `module github.com/You/your-app
+
+go 1.16
+
+require (
+	github.com/jbowes/semver v0.1.3 // retract v0.0.1
+)
+
+// Published by accident.
+retract v0.9.0
+
+retract [v0.1.0, v0.1.5] // Bad parsing.
+
+// This comment is not attached.
+
+retract v0.2.0
+
+// Security issues.
+retract (
+	v0.3.0
+	// Broken build.
+	// Do not use.
+	"v0.4.0"
+	[v0.5.0, v0.5.2] // Data loss.
+)
+`
+
+func statusServer(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+func TestGoProxyReleaser(t *testing.T) {
+	srv := goProxyServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "github.com/You/your-app",
+		Proxy:   srv.URL + "/",
+		NoProxy: "example.com/private",
+		Client:  srv.Client(),
+	}
+
+	rels, etag, err := gpr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	tags := []string{"v1.0.0", "v1.1.0", "v1.2.0-rc.1"}
+	if len(rels) != len(tags) {
+		t.Fatalf("wrong number of releases. expected: %d got: %d", len(tags), len(rels))
+	}
+	for i, tag := range tags {
+		if rels[i].TagName != tag {
+			t.Errorf("wrong tag name. expected: %s got: %s", tag, rels[i].TagName)
+		}
+	}
+
+	published := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	if !rels[1].PublishedAt.Equal(published) {
+		t.Errorf("wrong published at. expected: %s got: %s", published, rels[1].PublishedAt)
+	}
+
+	if etag != `"list-1"` {
+		t.Errorf("wrong etag. expected: %s got: %s", `"list-1"`, etag)
+	}
+}
+
+func TestGoProxyReleaser_latestNotInList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.com/app/@v/list":
+		case "/example.com/app/@latest":
+			fmt.Fprint(w, `{"Version": "v0.0.0-20210401120000-abcdefabcdef"}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "example.com/app",
+		Proxy:   srv.URL,
+		NoProxy: "none",
+		Client:  srv.Client(),
+	}
+
+	rels, _, err := gpr.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+	if len(rels) != 1 || rels[0].TagName != "v0.0.0-20210401120000-abcdefabcdef" {
+		t.Errorf("wrong releases. got: %+v", rels)
+	}
+}
+
+func TestGoProxyReleaser_proxyList(t *testing.T) {
+	good := goProxyServer(t)
+	defer good.Close()
+	notFound := statusServer(http.StatusNotFound)
+	defer notFound.Close()
+	gone := statusServer(http.StatusGone)
+	defer gone.Close()
+	broken := statusServer(http.StatusInternalServerError)
+	defer broken.Close()
+
+	tcs := map[string]struct {
+		proxy string
+		ok    bool
+	}{
+		"single":                    {proxy: good.URL, ok: true},
+		"comma falls back on 404":   {proxy: notFound.URL + "," + good.URL, ok: true},
+		"comma falls back on 410":   {proxy: gone.URL + "," + good.URL, ok: true},
+		"comma stops on error":      {proxy: broken.URL + "," + good.URL, ok: false},
+		"pipe falls back on error":  {proxy: broken.URL + "|" + good.URL, ok: true},
+		"pipe falls back on 404":    {proxy: notFound.URL + "|" + good.URL, ok: true},
+		"mixed separators":          {proxy: notFound.URL + "," + broken.URL + "|" + good.URL, ok: true},
+		"direct ignored":            {proxy: "direct," + good.URL, ok: true},
+		"trailing direct ignored":   {proxy: notFound.URL + ",direct", ok: false},
+		"only direct":               {proxy: "direct", ok: false},
+		"off":                       {proxy: "off", ok: false},
+		"off after fallback":        {proxy: notFound.URL + ",off," + good.URL, ok: false},
+		"last error returned":       {proxy: notFound.URL + "," + broken.URL, ok: false},
+		"empty entries are skipped": {proxy: ",," + good.URL, ok: true},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			gpr := &impl.GoProxyReleaser{
+				Module:  "github.com/You/your-app",
+				Proxy:   tc.proxy,
+				NoProxy: "none",
+				Client:  good.Client(),
+			}
+
+			rels, _, err := gpr.Get(ctx, "")
+			if tc.ok && (err != nil || len(rels) == 0) {
+				t.Errorf("expected releases. got: %v, err: %v", rels, err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("expected error but got none")
+			}
+		})
+	}
+}
+
+func TestGoProxyReleaser_noProxy(t *testing.T) {
+	srv := goProxyServer(t)
+	defer srv.Close()
+
+	tcs := map[string]struct {
+		noProxy string
+		skip    bool
+	}{
+		"exact":            {noProxy: "github.com/You/your-app", skip: true},
+		"prefix":           {noProxy: "github.com/You", skip: true},
+		"glob":             {noProxy: "*.com/You", skip: true},
+		"list":             {noProxy: "example.com, github.com/You/", skip: true},
+		"no match":         {noProxy: "github.com/Yo", skip: false},
+		"longer than path": {noProxy: "github.com/You/your-app/v2", skip: false},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			gpr := &impl.GoProxyReleaser{
+				Module:  "github.com/You/your-app",
+				Proxy:   srv.URL,
+				NoProxy: tc.noProxy,
+				Client:  srv.Client(),
+			}
+
+			_, _, err := gpr.Get(ctx, "")
+			if tc.skip && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tc.skip && err != nil {
+				t.Errorf("got unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestGoProxyReleaser_environment(t *testing.T) {
+	srv := goProxyServer(t)
+	defer srv.Close()
+
+	testenv.Setenv(t, "GOPROXY", srv.URL)
+	testenv.Setenv(t, "GONOPROXY", "")
+	testenv.Setenv(t, "GOPRIVATE", "github.com/You")
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{Module: "github.com/You/your-app", Client: srv.Client()}
+	if _, _, err := gpr.Get(ctx, ""); err == nil {
+		t.Error("expected GOPRIVATE module to error but got none")
+	}
+
+	// GONOPROXY takes precedence over GOPRIVATE
+	testenv.Setenv(t, "GONOPROXY", "example.com")
+	if _, _, err := gpr.Get(ctx, ""); err != nil {
+		t.Errorf("got unexpected error: %s", err)
+	}
+}
+
+func TestGoProxyReleaser_supportsEtag(t *testing.T) {
+	srv := goProxyServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "github.com/You/your-app",
+		Proxy:   srv.URL,
+		NoProxy: "none",
+		Client:  srv.Client(),
+	}
+
+	etag := `"list-1"`
+	rels, outEtag, err := gpr.Get(ctx, etag)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(rels) != 0 {
+		t.Error("expected no rels but got some")
+	}
+	if outEtag != etag {
+		t.Error("incorrect etag. wanted:", etag, "got:", outEtag)
+	}
+}
+
+func TestGoProxyReleaser_retractions(t *testing.T) {
+	srv := goProxyServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "github.com/You/your-app",
+		Proxy:   srv.URL,
+		NoProxy: "none",
+		Client:  srv.Client(),
+	}
+
+	rets, err := gpr.Retractions(ctx)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	want := []impl.Retraction{
+		{Low: "v0.9.0", High: "v0.9.0", Rationale: "Published by accident."},
+		{Low: "v0.1.0", High: "v0.1.5", Rationale: "Bad parsing."},
+		{Low: "v0.2.0", High: "v0.2.0"},
+		{Low: "v0.3.0", High: "v0.3.0", Rationale: "Security issues."},
+		{Low: "v0.4.0", High: "v0.4.0", Rationale: "Broken build.\nDo not use."},
+		{Low: "v0.5.0", High: "v0.5.2", Rationale: "Data loss."},
+	}
+
+	if len(rets) != len(want) {
+		t.Fatalf("wrong number of retractions. expected: %d got: %d (%+v)", len(want), len(rets), rets)
+	}
+	for i := range want {
+		if rets[i] != want[i] {
+			t.Errorf("wrong retraction. expected: %+v got: %+v", want[i], rets[i])
+		}
+	}
+}
+
+func TestGoProxyReleaser_retractionsSelfRetracted(t *testing.T) {
+	lists := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.com/app/@v/list":
+			lists++
+			fmt.Fprint(w, "v1.0.0\nv1.0.1\nv1.1.0-rc.1\n")
+		case "/example.com/app/@latest":
+			// The proxy excludes retracted versions from @latest.
+			fmt.Fprint(w, `{"Version": "v1.0.0"}`)
+		case "/example.com/app/@v/v1.0.0.mod":
+			fmt.Fprint(w, "module example.com/app\n")
+		case "/example.com/app/@v/v1.0.1.mod":
+			fmt.Fprint(w, "module example.com/app\n\n// Broken.\nretract [v1.0.0, v1.0.1]\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "example.com/app",
+		Proxy:   srv.URL,
+		NoProxy: "none",
+		Client:  srv.Client(),
+	}
+
+	if _, _, err := gpr.Get(ctx, ""); err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	rets, err := gpr.Retractions(ctx)
+	if err != nil {
+		t.Fatalf("got unexpected error: %s", err)
+	}
+
+	want := impl.Retraction{Low: "v1.0.0", High: "v1.0.1", Rationale: "Broken."}
+	if len(rets) != 1 || rets[0] != want {
+		t.Errorf("wrong retractions. expected: %+v got: %+v", want, rets)
+	}
+	if lists != 1 {
+		t.Errorf("expected version list to be reused. got: %d requests", lists)
+	}
+}
+
+func TestGoProxyReleaser_retractionsErrorOnMissingMod(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/example.com/app/@v/list" {
+			fmt.Fprint(w, "v1.0.0\n")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	gpr := &impl.GoProxyReleaser{
+		Module:  "example.com/app",
+		Proxy:   srv.URL,
+		NoProxy: "none",
+		Client:  srv.Client(),
+	}
+
+	if _, err := gpr.Retractions(ctx); err == nil {
+		t.Error("expected error but got none")
+	}
+}
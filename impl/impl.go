@@ -8,6 +8,7 @@ package impl
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -20,11 +21,44 @@ type Cacher interface {
 	Set(context.Context, *Info) error
 }
 
+// Leaser is an optional interface a Cacher may implement to deduplicate
+// release checks across processes sharing a cache. Only the process
+// holding the lease checks for releases; the others briefly wait for
+// its result, or use their cached Info.
+type Leaser interface {
+	// Lease tries to take the lease on running a release check, until
+	// ttl passes. If another holder has an unexpired lease, ok is false.
+	// Otherwise, call release once the result is cached.
+	Lease(ctx context.Context, ttl time.Duration) (release func(), ok bool, err error)
+}
+
 // Info is cached information about the newest last-seen release.
 type Info struct {
+	Schema int `json:"schema"` // The version of the cache format. See SchemaVersion.
+
 	CheckTime time.Time `json:"check_time"` // When the check was last run
 	Version   string    `json:"version"`    // The largest/newest version seen in the last check
 	Etag      string    `json:"etag"`       // An entity tag to aid in refetchin.
+
+	RetryAfter  time.Time `json:"retry_after"`  // Do not check for releases before this time
+	Failures    int       `json:"failures"`     // The number of consecutive failed checks
+	LastFailure time.Time `json:"last_failure"` // When the last failed check was run
+
+	Name        string    `json:"name"`         // The name of the release for Version
+	URL         string    `json:"url"`          // A link to the release for Version
+	PublishedAt time.Time `json:"published_at"` // When the release for Version was published
+	Prerelease  bool      `json:"prerelease"`   // If the release for Version is marked as a prerelease
+	Selection   string    `json:"selection"`    // The options Version was chosen with, if not the defaults
+
+	Retractions []Retraction `json:"retractions"` // Retracted versions, if supported by the Releaser
+
+	LastRunVersion string `json:"last_run_version"` // The version of the program that last ran a check
+
+	// Releases are the newest releases, if caching them is enabled.
+	// Their bodies may be truncated, and their assets are not kept.
+	Releases []Release `json:"releases"`
+
+	unknown map[string]json.RawMessage // Fields from newer schemas
 }
 
 // Releaser gets a list of releases from a source.
@@ -40,10 +74,58 @@ type Releaser interface {
 	Get(ctx context.Context, etag string) (releases []Release, newEtag string, err error)
 }
 
+// Retracter is an optional interface a Releaser may implement to report
+// versions that have been retracted by their publisher, such as with
+// the `retract` directive in a go.mod file.
+type Retracter interface {
+	// Retractions gets the list of retracted versions.
+	Retractions(ctx context.Context) ([]Retraction, error)
+}
+
+// History is an optional interface a Releaser may implement to get
+// releases older than those returned by Get, such as from further pages
+// of a paginated API.
+type History interface {
+	// History gets releases, in the same order as Get, until done
+	// reports that enough have been fetched, or there are no more.
+	History(ctx context.Context, done func([]Release) bool) ([]Release, error)
+}
+
+// Identifier is an optional interface a Releaser may implement to
+// identify where its releases come from, such as to key the entries in
+// a KeyedFileCacher.
+type Identifier interface {
+	// ID returns an identifier unique to the source of releases.
+	ID() string
+}
+
+// Retraction is a range of retracted versions, from Low to High
+// inclusive. A single retracted version has the same Low and High.
+type Retraction struct {
+	Low       string `json:"low"`
+	High      string `json:"high"`
+	Rationale string `json:"rationale"` // Why the versions were retracted, if given.
+}
+
 // Release is a single release entry from a releaser.
 // It is modeled after the fields in GitHub releases.
 type Release struct {
 	Draft      bool   `json:"draft"`
 	Prerelease bool   `json:"prerelease"`
 	TagName    string `json:"tag_name"`
+
+	Name        string    `json:"name"`
+	Body        string    `json:"body"` // Release notes, usually in markdown.
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// Asset is a file attached to a Release.
+// It is modeled after the fields in GitHub release assets.
+type Asset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	ContentType        string `json:"content_type"`
 }
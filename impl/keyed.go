@@ -0,0 +1,219 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMaxEntryAge is how long a KeyedFileCacher keeps entries that
+// have not been checked, if MaxAge is not set.
+const DefaultMaxEntryAge = 90 * 24 * time.Hour
+
+// KeyedFileCacher is a Cacher that stores Info for many applications in
+// a single file, each under its own Key. Use it to share one cache file
+// between a suite of applications.
+//
+// Like FileCacher, updates are written atomically under an advisory
+// lock. Entries that have not been checked within MaxAge are pruned
+// when the file is updated. Corrupt files are moved aside, as with
+// FileCacher, but an entry with a CheckTime in the future is dropped on
+// its own, keeping the entries of other applications.
+//
+// A single-entry file written by FileCacher is migrated to an entry for
+// the Key of the first KeyedFileCacher to use it, so point Path at the
+// previous FileCacher path of one application only.
+type KeyedFileCacher struct {
+	Path string
+	Key  string // identifies this application's entry, eg its slug.
+
+	// MaxAge is how long to keep entries that have not been checked.
+	// If not set, DefaultMaxEntryAge is used.
+	MaxAge time.Duration
+}
+
+// keyedFile is the contents of a KeyedFileCacher's file.
+type keyedFile struct {
+	Entries map[string]*Info `json:"entries"`
+
+	skew error // why the entry for the decoded key was dropped, if it was.
+}
+
+// Get cached release Info for Key. If the file is corrupt, it is moved
+// aside, and a *CorruptCacheError is returned. If only the entry for Key
+// is corrupt, from clock skew, it is ignored, and a *CorruptCacheError
+// is returned without a Quarantine.
+func (k *KeyedFileCacher) Get(ctx context.Context) (*Info, error) {
+	data, err := readValid(ctx, k.Path, func(data []byte) error {
+		_, err := decodeKeyed(data, k.Key, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kf, err := decodeKeyed(data, k.Key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	i := kf.Entries[k.Key]
+	if i == nil && kf.skew != nil {
+		return nil, &CorruptCacheError{Path: k.Path, Err: kf.skew}
+	}
+	if i == nil {
+		return nil, fmt.Errorf("no cache entry for %q: %w", k.Key, fs.ErrNotExist)
+	}
+
+	i.migrate()
+	return i, nil
+}
+
+// Set cached release Info for Key, keeping the entries of other
+// applications.
+func (k *KeyedFileCacher) Set(ctx context.Context, i *Info) error {
+	if err := os.MkdirAll(filepath.Dir(k.Path), 0750); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(ctx, k.Path+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// A missing file is created, and a file that can't be parsed is
+	// replaced. Otherwise, the other entries are kept.
+	kf, err := readKeyed(k.Path, k.Key)
+	if err != nil && kf == nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		kf = &keyedFile{Entries: map[string]*Info{}}
+	}
+	kf.Entries[k.Key] = i
+	kf.prune(k.Key, time.Now(), k.maxAge())
+
+	return writeJSON(k.Path, kf)
+}
+
+// Lease the release check for Key, by writing a `.lease` file next to
+// Path. Applications with different keys lease their checks separately.
+func (k *KeyedFileCacher) Lease(ctx context.Context, ttl time.Duration) (func(), bool, error) {
+	sum := sha256.Sum256([]byte(k.Key))
+	leasePath := fmt.Sprintf("%s.%s.lease", k.Path, hex.EncodeToString(sum[:8]))
+
+	return leaseFile(ctx, k.Path, leasePath, ttl)
+}
+
+func (k *KeyedFileCacher) maxAge() time.Duration {
+	if k.MaxAge <= 0 {
+		return DefaultMaxEntryAge
+	}
+
+	return k.MaxAge
+}
+
+// readKeyed reads the keyed file at path. If the file can't be read,
+// the error is returned. If it is corrupt, an empty keyedFile is
+// returned with the error.
+func readKeyed(path, key string) (*keyedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	kf, err := decodeKeyed(data, key, time.Now())
+	if err != nil {
+		return &keyedFile{Entries: map[string]*Info{}}, err
+	}
+
+	return kf, nil
+}
+
+// decodeKeyed decodes and checks the keyed file in data. A single-entry
+// file, as written by FileCacher, is returned as the entry for key.
+// Entries with a CheckTime in the future are dropped, leaving the
+// entries of other applications. If the entry for key is dropped, the
+// reason is kept in skew.
+func decodeKeyed(data []byte, key string, now time.Time) (*keyedFile, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errEmptyCache
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if _, ok := raw["entries"]; !ok {
+		i, err := decodeInfo(data, now)
+		if err != nil {
+			return nil, err
+		}
+
+		return &keyedFile{Entries: map[string]*Info{key: i}}, nil
+	}
+
+	var kf keyedFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, err
+	}
+
+	if kf.Entries == nil {
+		kf.Entries = map[string]*Info{}
+	}
+
+	for k, i := range kf.Entries {
+		if i == nil {
+			continue
+		}
+
+		if err := checkTime(i, now); err != nil {
+			delete(kf.Entries, k)
+			if k == key {
+				kf.skew = err
+			}
+		}
+	}
+
+	return &kf, nil
+}
+
+// prune removes entries, other than keep, that have not been checked,
+// nor are waiting to be checked, within maxAge of now.
+func (kf *keyedFile) prune(keep string, now time.Time, maxAge time.Duration) {
+	for key, i := range kf.Entries {
+		if key == keep {
+			continue
+		}
+
+		if i == nil {
+			delete(kf.Entries, key)
+			continue
+		}
+
+		last := i.CheckTime
+		for _, t := range []time.Time{i.LastFailure, i.RetryAfter} {
+			if t.After(last) {
+				last = t
+			}
+		}
+
+		if now.Sub(last) > maxAge {
+			delete(kf.Entries, key)
+		}
+	}
+}
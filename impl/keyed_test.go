@@ -0,0 +1,166 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+func TestKeyedFileCacher_entries(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache", "updates.json")
+
+	now := time.Now().Round(0)
+	entries := map[string]*impl.Info{
+		"you/your-app":   {CheckTime: now, Version: "v1.0.1", Etag: "etag-1"},
+		"you/other-app":  {CheckTime: now, Version: "v2.3.0", Etag: "etag-2"},
+		"them/their-app": {CheckTime: now, Version: "v0.1.0", Etag: "etag-3"},
+	}
+
+	for key, i := range entries {
+		kc := impl.KeyedFileCacher{Path: path, Key: key}
+		if err := kc.Set(ctx, i); err != nil {
+			t.Fatalf("error running set: %s", err)
+		}
+	}
+
+	for key, want := range entries {
+		kc := impl.KeyedFileCacher{Path: path, Key: key}
+		got, err := kc.Get(ctx)
+		if err != nil {
+			t.Fatalf("error running get: %s", err)
+		}
+
+		if got.Version != want.Version || got.Etag != want.Etag || !got.CheckTime.Equal(want.CheckTime) {
+			t.Errorf("entry for %s did not match. got: %+v, want: %+v", key, got, want)
+		}
+	}
+}
+
+func TestKeyedFileCacher_missingEntry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+	if _, err := kc.Get(ctx); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error. got: %v", err)
+	}
+
+	other := impl.KeyedFileCacher{Path: path, Key: "you/other-app"}
+	if err := other.Set(ctx, &impl.Info{CheckTime: time.Now(), Version: "v1.0.0"}); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	if _, err := kc.Get(ctx); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error. got: %v", err)
+	}
+}
+
+func TestKeyedFileCacher_migrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	now := time.Now().Round(0)
+	fc := impl.FileCacher{Path: path}
+	if err := fc.Set(ctx, &impl.Info{CheckTime: now, Version: "v1.0.1", Etag: "etag-1"}); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+	got, err := kc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+	if got.Version != "v1.0.1" || got.Etag != "etag-1" {
+		t.Errorf("expected migrated entry. got: %+v", got)
+	}
+
+	if err := kc.Set(ctx, got); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	// Updating another key keeps the migrated entry.
+	other := impl.KeyedFileCacher{Path: path, Key: "you/other-app"}
+	if err := other.Set(ctx, &impl.Info{CheckTime: now, Version: "v2.0.0"}); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	got, err = kc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+	if got.Version != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", got.Version, "v1.0.1")
+	}
+}
+
+func TestKeyedFileCacher_prune(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+	now := time.Now()
+
+	tcs := map[string]struct {
+		info *impl.Info
+		kept bool
+	}{
+		"recent check":       {&impl.Info{CheckTime: now.Add(-time.Hour)}, true},
+		"old check":          {&impl.Info{CheckTime: now.Add(-48 * time.Hour)}, false},
+		"recent failure":     {&impl.Info{CheckTime: now.Add(-48 * time.Hour), LastFailure: now.Add(-time.Hour)}, true},
+		"pending rate limit": {&impl.Info{RetryAfter: now.Add(time.Hour)}, true},
+	}
+
+	for key, tc := range tcs {
+		kc := impl.KeyedFileCacher{Path: path, Key: key, MaxAge: 24 * time.Hour}
+		if err := kc.Set(ctx, tc.info); err != nil {
+			t.Fatalf("error running set: %s", err)
+		}
+	}
+
+	// Pruning happens on the next update.
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app", MaxAge: 24 * time.Hour}
+	if err := kc.Set(ctx, &impl.Info{CheckTime: now}); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	for key, tc := range tcs {
+		t.Run(key, func(t *testing.T) {
+			kc := impl.KeyedFileCacher{Path: path, Key: key}
+			_, err := kc.Get(ctx)
+			if kept := err == nil; kept != tc.kept {
+				t.Errorf("entry kept did not match. got: %t, want: %t", kept, tc.kept)
+			}
+		})
+	}
+}
+
+func TestKeyedFileCacher_leasePerKey(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+	release, ok, err := kc.Lease(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected lease. got: %t, err: %v", ok, err)
+	}
+	defer release()
+
+	if _, ok, err := kc.Lease(ctx, time.Minute); err != nil || ok {
+		t.Errorf("expected lease to be held. got: %t, err: %v", ok, err)
+	}
+
+	other := impl.KeyedFileCacher{Path: path, Key: "you/other-app"}
+	otherRelease, ok, err := other.Lease(ctx, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected lease for other key. got: %t, err: %v", ok, err)
+	}
+	otherRelease()
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// lockRetry is how often to retry taking a held lock.
+const lockRetry = 10 * time.Millisecond
+
+// lockFile takes an exclusive advisory lock on the file at path,
+// creating it if needed. It waits for the lock until ctx is done.
+// The returned func releases the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if ok {
+			return func() {
+				_ = unlock(f)
+				f.Close()
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+}
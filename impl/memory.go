@@ -0,0 +1,44 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+)
+
+// MemoryCacher is a Cacher that holds Info in memory. Results are not
+// kept between runs of a program, so a release check is run at most
+// once per run. whatsnew falls back to it when there is nowhere to
+// write a cache file.
+type MemoryCacher struct {
+	mu   sync.Mutex
+	info *Info
+}
+
+// Get cached release Info.
+func (m *MemoryCacher) Get(context.Context) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.info == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	i := *m.info
+	return &i, nil
+}
+
+// Set cached release Info.
+func (m *MemoryCacher) Set(_ context.Context, i *Info) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ni := *i
+	m.info = &ni
+
+	return nil
+}
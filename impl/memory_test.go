@@ -0,0 +1,42 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+func TestMemoryCacher_roundTrip(t *testing.T) {
+	ctx := context.Background()
+	mc := impl.MemoryCacher{}
+
+	if _, err := mc.Get(ctx); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error. got: %v", err)
+	}
+
+	now := time.Now()
+	in := &impl.Info{CheckTime: now, Version: "v1.1.2", Etag: "some-etag"}
+	if err := mc.Set(ctx, in); err != nil {
+		t.Errorf("error running set: %s", err)
+	}
+
+	// Changes after Set are not cached.
+	in.Version = "v1.1.3"
+
+	out, err := mc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+
+	if !out.CheckTime.Equal(now) || out.Version != "v1.1.2" || out.Etag != "some-etag" {
+		t.Errorf("Info wrong. get: %+v", out)
+	}
+}
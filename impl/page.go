@@ -0,0 +1,109 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// page is a single page of results from a paginated releases API.
+type page struct {
+	releases    []Release
+	next        string // URL of the next page, if any.
+	etag        string
+	notModified bool
+}
+
+// withPageSize returns u with the page size query parameter param set
+// to n, unless u already sets it.
+func withPageSize(u, param string, n int) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	q := pu.Query()
+	if q.Get(param) != "" {
+		return u
+	}
+
+	q.Set(param, strconv.Itoa(n))
+	pu.RawQuery = q.Encode()
+	return pu.String()
+}
+
+// resolveNext resolves the next page link against the URL of the
+// current page. Links to another scheme or host are not followed, so
+// tokens are only sent where they are meant to go.
+func resolveNext(cur *url.URL, link string) string {
+	u, err := cur.Parse(link)
+	if err != nil || u.Scheme != cur.Scheme || u.Host != cur.Host {
+		return ""
+	}
+
+	return u.String()
+}
+
+// pageGetter gets a single page of releases from url. If etag is set,
+// the request should be conditional.
+type pageGetter func(ctx context.Context, url, etag string) (*page, error)
+
+// getPages gets releases page by page, starting at url, until there are
+// no more pages, or maxPages is reached. The etag only applies to the
+// first page; if it has not changed, no further pages are fetched.
+func getPages(ctx context.Context, url, etag string, maxPages int, get pageGetter) ([]Release, string, error) {
+	var rels []Release
+	newEtag := ""
+	next := url
+	for i := 0; i < maxPages && next != ""; i++ {
+		pageEtag := ""
+		if i == 0 {
+			pageEtag = etag
+		}
+
+		p, err := get(ctx, next, pageEtag)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if p.notModified {
+			return nil, etag, nil // this will fall back to existing stuff.
+		}
+
+		if i == 0 {
+			newEtag = p.etag
+		}
+
+		rels = append(rels, p.releases...)
+		next = p.next
+	}
+
+	return rels, newEtag, nil
+}
+
+// getHistory gets releases page by page, starting at url, until done
+// reports that enough releases have been fetched, there are no more
+// pages, or maxPages is reached.
+func getHistory(ctx context.Context, url string, maxPages int, get pageGetter, done func([]Release) bool) ([]Release, error) {
+	var rels []Release
+	next := url
+	for i := 0; i < maxPages && next != ""; i++ {
+		p, err := get(ctx, next, "")
+		if err != nil {
+			return nil, err
+		}
+
+		rels = append(rels, p.releases...)
+		if done(rels) {
+			break
+		}
+
+		next = p.next
+	}
+
+	return rels, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRetryAfter is how long to wait after a rate limited response
+// that does not say when to retry.
+const defaultRetryAfter = time.Minute
+
+// RateLimitError is returned by a Releaser when its release source has
+// rate limited requests. You must use `errors.As` to check for this
+// error.
+type RateLimitError struct {
+	Status     string    // The HTTP status of the rate limited response.
+	RetryAfter time.Time // When requests may be retried.
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited until %s: %s", e.RetryAfter.Format(time.RFC3339), e.Status)
+}
+
+// rateLimited returns a RateLimitError if resp was rate limited, based on
+// its status and the Retry-After and X-RateLimit-* headers. Otherwise, it
+// returns nil. Retry times that have already passed are ignored.
+func rateLimited(resp *http.Response, now time.Time) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	e := RateLimitError{Status: resp.Status}
+
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.ParseInt(ra, 10, 32); err == nil && secs > 0 {
+			e.RetryAfter = now.Add(time.Duration(secs) * time.Second)
+		} else if t, err := http.ParseTime(ra); err == nil && t.After(now) {
+			e.RetryAfter = t
+		}
+	}
+
+	if e.RetryAfter.IsZero() && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil && time.Unix(reset, 0).After(now) {
+			e.RetryAfter = time.Unix(reset, 0)
+		}
+	}
+
+	if e.RetryAfter.IsZero() {
+		// A plain 403 is a permissions problem, not a rate limit.
+		if resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") != "0" {
+			return nil
+		}
+
+		e.RetryAfter = now.Add(defaultRetryAfter)
+	}
+
+	return &e
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaVersion is the version of the Info cache format written by this
+// version of whatsnew. Info from older versions is migrated when read.
+//
+// Changes to the format must be additive. Info written by a newer
+// version keeps its Schema and any fields this version does not know
+// when written back, so older programs sharing a cache don't drop data.
+const SchemaVersion = 1
+
+// migrations upgrade Info from the schema at their index to the next.
+var migrations = []func(*Info){
+	// Schema 0 predates the schema field. Its fields are unchanged.
+	0: func(*Info) {},
+}
+
+// migrate upgrades i to SchemaVersion. Info from newer schemas is left
+// as is.
+func (i *Info) migrate() {
+	if i.Schema < 0 {
+		i.Schema = 0
+	}
+
+	for i.Schema < SchemaVersion {
+		migrations[i.Schema](i)
+		i.Schema++
+	}
+}
+
+// infoJSON has the fields of Info without its JSON methods.
+type infoJSON Info
+
+// infoKeys are the JSON keys of the fields known to this version.
+var infoKeys = func() map[string]bool {
+	keys := map[string]bool{}
+
+	t := reflect.TypeOf(infoJSON{})
+	for n := 0; n < t.NumField(); n++ {
+		if tag := t.Field(n).Tag.Get("json"); tag != "" {
+			keys[strings.Split(tag, ",")[0]] = true
+		}
+	}
+
+	return keys
+}()
+
+// MarshalJSON encodes Info, including any unknown fields it was decoded
+// with. Schema is at least SchemaVersion.
+func (i Info) MarshalJSON() ([]byte, error) {
+	ij := infoJSON(i)
+	if ij.Schema < SchemaVersion {
+		ij.Schema = SchemaVersion
+	}
+
+	b, err := json.Marshal(ij)
+	if err != nil || len(i.unknown) == 0 {
+		return b, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	for k, v := range i.unknown {
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON decodes Info, keeping any fields unknown to this
+// version, so they are preserved when it is encoded again.
+func (i *Info) UnmarshalJSON(b []byte) error {
+	var ij infoJSON
+	if err := json.Unmarshal(b, &ij); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	ij.unknown = nil
+	for k, v := range fields {
+		if !infoKeys[k] {
+			if ij.unknown == nil {
+				ij.unknown = map[string]json.RawMessage{}
+			}
+			ij.unknown[k] = v
+		}
+	}
+
+	*i = Info(ij)
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// readFields reads the JSON object in the file at path.
+func readFields(t *testing.T, path string) map[string]json.RawMessage {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading cache: %s", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("error decoding cache: %s", err)
+	}
+
+	return fields
+}
+
+func TestFileCacher_schemaUpgrade(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test-cache.json")
+
+	// A cache written before the schema field was added.
+	old := `{"check_time": "2021-03-01T10:00:00Z", "version": "v1.1.2", "etag": "some-etag"}`
+	if err := os.WriteFile(path, []byte(old), 0600); err != nil {
+		t.Fatal("couldn't set up cache")
+	}
+
+	fc := impl.FileCacher{Path: path}
+	i, err := fc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+
+	if i.Schema != impl.SchemaVersion {
+		t.Errorf("schema did not match. got: %d, want: %d", i.Schema, impl.SchemaVersion)
+	}
+	if i.Version != "v1.1.2" || i.Etag != "some-etag" {
+		t.Errorf("expected fields to be kept. got: %+v", i)
+	}
+
+	if err := fc.Set(ctx, i); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	fields := readFields(t, path)
+	if got := string(fields["schema"]); got != "1" {
+		t.Errorf("schema did not match. got: %s, want: %s", got, "1")
+	}
+}
+
+func TestFileCacher_schemaDowngrade(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test-cache.json")
+
+	// A cache written by a newer version, with fields unknown to this one.
+	newer := `{
+		"schema": 99,
+		"check_time": "2021-03-01T10:00:00Z",
+		"version": "v1.1.2",
+		"future_field": {"nested": ["value"]},
+		"future_flag": true
+	}`
+	if err := os.WriteFile(path, []byte(newer), 0600); err != nil {
+		t.Fatal("couldn't set up cache")
+	}
+
+	fc := impl.FileCacher{Path: path}
+	i, err := fc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+
+	if i.Schema != 99 {
+		t.Errorf("schema did not match. got: %d, want: %d", i.Schema, 99)
+	}
+
+	// Update the Info, as a release check would.
+	ni := *i
+	ni.Version = "v1.1.3"
+	if err := fc.Set(ctx, &ni); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	fields := readFields(t, path)
+	tcs := map[string]string{
+		"schema":       `99`,
+		"version":      `"v1.1.3"`,
+		"future_field": `{"nested":["value"]}`,
+		"future_flag":  `true`,
+	}
+	for k, want := range tcs {
+		var got interface{}
+		if err := json.Unmarshal(fields[k], &got); err != nil {
+			t.Errorf("field %s missing: %s", k, err)
+			continue
+		}
+
+		gb, _ := json.Marshal(got)
+		if string(gb) != want {
+			t.Errorf("field %s did not match. got: %s, want: %s", k, gb, want)
+		}
+	}
+}
+
+func TestKeyedFileCacher_schemaDowngrade(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	newer := `{"entries": {
+		"you/your-app": {"schema": 99, "version": "v1.1.2", "future_flag": true},
+		"you/other-app": {"schema": 99, "retry_after": "2999-01-01T00:00:00Z", "future_flag": false}
+	}}`
+	if err := os.WriteFile(path, []byte(newer), 0600); err != nil {
+		t.Fatal("couldn't set up cache")
+	}
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+	i, err := kc.Get(ctx)
+	if err != nil {
+		t.Fatalf("error running get: %s", err)
+	}
+
+	if err := kc.Set(ctx, i); err != nil {
+		t.Fatalf("error running set: %s", err)
+	}
+
+	var kf struct {
+		Entries map[string]map[string]json.RawMessage `json:"entries"`
+	}
+	b, _ := os.ReadFile(path)
+	if err := json.Unmarshal(b, &kf); err != nil {
+		t.Fatalf("error decoding cache: %s", err)
+	}
+
+	for key, want := range map[string]string{"you/your-app": "true", "you/other-app": "false"} {
+		if got := string(kf.Entries[key]["future_flag"]); got != want {
+			t.Errorf("future_flag for %s did not match. got: %s, want: %s", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// GitHubToken discovers a token for the GitHub host, eg `github.com`.
+// The first token found is returned, from:
+//
+//   - the GH_TOKEN or GITHUB_TOKEN environment variables. For GitHub
+//     Enterprise Server hosts, GH_ENTERPRISE_TOKEN or
+//     GITHUB_ENTERPRISE_TOKEN are used instead.
+//   - the GitHub CLI's hosts.yml file
+//   - the .netrc file, for either the host or its API host
+//
+// If no token is found, the empty string is returned.
+func GitHubToken(host string) string {
+	envs := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != "github.com" {
+		envs = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+
+	for _, k := range envs {
+		if t := os.Getenv(k); t != "" {
+			return t
+		}
+	}
+
+	if t := ghHostsToken(host); t != "" {
+		return t
+	}
+
+	return netrcToken("api."+host, host)
+}
+
+// ghHostsToken reads a token for host from the GitHub CLI's hosts.yml.
+// Only the simple YAML used by the GitHub CLI is understood.
+func ghHostsToken(host string) string {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			dir = filepath.Join(xdg, "gh")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gh")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+
+	f, err := os.Open(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	inHost := false
+	childIndent := -1
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == 0 {
+			inHost = yamlUnquote(strings.TrimSuffix(trimmed, ":")) == host
+			childIndent = -1
+			continue
+		}
+
+		if !inHost {
+			continue
+		}
+
+		// Only look at direct children of the host, not nested users.
+		if childIndent < 0 {
+			childIndent = indent
+		}
+		if indent != childIndent {
+			continue
+		}
+
+		if kv := strings.SplitN(trimmed, ":", 2); len(kv) == 2 && strings.TrimSpace(kv[0]) == "oauth_token" {
+			return yamlUnquote(strings.TrimSpace(kv[1]))
+		}
+	}
+
+	return ""
+}
+
+func yamlUnquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// netrcEntry is a machine, or the default, in a .netrc file.
+type netrcEntry struct {
+	machine  string // empty for the default entry.
+	password string
+}
+
+// netrcToken reads the password for the first of machines found in the
+// .netrc file, falling back to the default entry.
+func netrcToken(machines ...string) string {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(home, name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var entries []netrcEntry
+	inMacdef := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if inMacdef {
+			inMacdef = len(fields) != 0 // macros end at a blank line.
+			continue
+		}
+
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{})
+				if i+1 < len(fields) {
+					i++
+					entries[len(entries)-1].machine = fields[i]
+				}
+			case "default":
+				entries = append(entries, netrcEntry{})
+			case "login", "account":
+				i++
+			case "password":
+				if i+1 < len(fields) && len(entries) != 0 {
+					i++
+					entries[len(entries)-1].password = fields[i]
+				}
+			case "macdef":
+				inMacdef = true
+				i = len(fields)
+			}
+		}
+	}
+
+	for _, m := range append(machines, "") {
+		for _, e := range entries {
+			if e.machine == m && e.password != "" {
+				return e.password
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
+)
+
+// tokenEnv sets up an isolated environment for token discovery, with
+// GitHub CLI configuration and .netrc files in a temporary directory.
+func tokenEnv(t *testing.T, hosts, netrc string) {
+	dir := t.TempDir()
+
+	for _, k := range []string{"GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN", "XDG_CONFIG_HOME"} {
+		testenv.Setenv(t, k, "")
+	}
+	testenv.Setenv(t, "HOME", dir)
+	testenv.Setenv(t, "GH_CONFIG_DIR", filepath.Join(dir, "gh"))
+	testenv.Setenv(t, "NETRC", filepath.Join(dir, "netrc"))
+
+	if hosts != "" {
+		if err := os.MkdirAll(filepath.Join(dir, "gh"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "gh", "hosts.yml"), []byte(hosts), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if netrc != "" {
+		if err := os.WriteFile(filepath.Join(dir, "netrc"), []byte(netrc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testHosts = `ghe.example.com:
+    oauth_token: ghe-token
+github.com:
+    users:
+        someone:
+            oauth_token: nested-token
+    user: someone
+    oauth_token: "hosts-token"
+    git_protocol: https
+`
+
+const testNetrc = `machine example.com login me password example-password
+macdef init
+  machine github.com password macro-password
+
+machine api.github.com
+  login me
+  password netrc-token
+default login anon password default-password
+`
+
+func TestGitHubToken(t *testing.T) {
+	tcs := map[string]struct {
+		env   map[string]string
+		hosts string
+		netrc string
+		host  string
+		token string
+	}{
+		"gh token": {
+			env:   map[string]string{"GH_TOKEN": "gh-token", "GITHUB_TOKEN": "github-token"},
+			hosts: testHosts,
+			host:  "github.com",
+			token: "gh-token",
+		},
+		"github token": {
+			env:   map[string]string{"GITHUB_TOKEN": "github-token"},
+			hosts: testHosts,
+			host:  "github.com",
+			token: "github-token",
+		},
+		"enterprise token": {
+			env:   map[string]string{"GITHUB_TOKEN": "github-token", "GH_ENTERPRISE_TOKEN": "gh-ghe-token", "GITHUB_ENTERPRISE_TOKEN": "github-ghe-token"},
+			hosts: testHosts,
+			host:  "ghe.example.com",
+			token: "gh-ghe-token",
+		},
+		"github enterprise token": {
+			env:   map[string]string{"GITHUB_TOKEN": "github-token", "GITHUB_ENTERPRISE_TOKEN": "github-ghe-token"},
+			host:  "ghe.example.com",
+			token: "github-ghe-token",
+		},
+		"enterprise token ignored for github": {
+			env:   map[string]string{"GH_ENTERPRISE_TOKEN": "gh-ghe-token"},
+			host:  "github.com",
+			token: "",
+		},
+		"hosts file": {
+			hosts: testHosts,
+			netrc: testNetrc,
+			host:  "github.com",
+			token: "hosts-token",
+		},
+		"hosts file other host": {
+			hosts: testHosts,
+			host:  "ghe.example.com",
+			token: "ghe-token",
+		},
+		"netrc api host": {
+			netrc: testNetrc,
+			host:  "github.com",
+			token: "netrc-token",
+		},
+		"netrc host": {
+			netrc: "machine github.com password host-token\n",
+			host:  "github.com",
+			token: "host-token",
+		},
+		"netrc default": {
+			netrc: testNetrc,
+			host:  "ghe.example.com",
+			token: "default-password",
+		},
+		"none": {
+			host:  "github.com",
+			token: "",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			tokenEnv(t, tc.hosts, tc.netrc)
+			for k, v := range tc.env {
+				testenv.Setenv(t, k, v)
+			}
+
+			if token := impl.GitHubToken(tc.host); token != tc.token {
+				t.Errorf("wrong token. expected: %q got: %q", tc.token, token)
+			}
+		})
+	}
+}
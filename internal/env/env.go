@@ -0,0 +1,45 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package env holds the environment variables that opt out of release
+// checks, shared by whatsnew and its tests.
+package env
+
+import (
+	"os"
+	"strings"
+)
+
+// OptOut are environment variables that disable release checks for all
+// applications.
+var OptOut = []string{
+	"WHATSNEW_DISABLE",
+	"DO_NOT_TRACK",
+}
+
+// CI are environment variables set by common CI services.
+var CI = []string{
+	"CI",
+	"CONTINUOUS_INTEGRATION",
+	"GITHUB_ACTIONS",
+	"GITLAB_CI",
+	"BUILDKITE",
+	"CIRCLECI",
+	"TRAVIS",
+	"JENKINS_URL",
+	"TEAMCITY_VERSION",
+	"TF_BUILD",
+	"BITBUCKET_BUILD_NUMBER",
+	"CODEBUILD_BUILD_ID",
+	"DRONE",
+	"APPVEYOR",
+	"SEMAPHORE",
+}
+
+// Set reports if the environment variable k is set to a value other
+// than the empty string, `0`, or `false`.
+func Set(k string) bool {
+	v := os.Getenv(k)
+	return v != "" && v != "0" && !strings.EqualFold(v, "false")
+}
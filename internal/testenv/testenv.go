@@ -0,0 +1,44 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package testenv provides environment helpers for whatsnew's tests.
+package testenv
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jbowes/whatsnew/internal/env"
+)
+
+// Clear unsets the environment variables that opt out of release
+// checks, so tests don't skip checks when they run in CI. The user
+// config is read from configHome, which should not exist, so tests
+// don't read the config of whoever runs them.
+func Clear(configHome string) {
+	for _, k := range append(append([]string{}, env.OptOut...), env.CI...) {
+		os.Unsetenv(k)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", configHome)
+}
+
+// Setenv sets an environment variable for the duration of the test.
+// An empty value unsets the variable.
+func Setenv(t *testing.T, k, v string) {
+	old, ok := os.LookupEnv(k)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(k, old)
+		} else {
+			os.Unsetenv(k)
+		}
+	})
+
+	if v == "" {
+		os.Unsetenv(k)
+	} else {
+		os.Setenv(k, v)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package notice_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jbowes/whatsnew"
+	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
+	"github.com/jbowes/whatsnew/notice"
+)
+
+type testReleaser struct{}
+
+func (testReleaser) Get(context.Context, string) ([]impl.Release, string, error) {
+	return []impl.Release{{
+		TagName: "v0.2.0",
+		HTMLURL: "https://github.com/you/your-app/releases/tag/v0.2.0",
+	}}, "", nil
+}
+
+func ExamplePrint() {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v0.1.0",
+		Cacher:   &impl.MemoryCacher{},
+		Releaser: testReleaser{},
+	})
+
+	// Run your CLI code and whatnot
+
+	// Notices are written to stderr by default.
+	res, _ := fut.Result()
+	_ = notice.Print(res, &notice.Options{Writer: os.Stdout, Style: notice.Box})
+
+	// Output:
+	// ╭─────────────────────────────────────────────────────╮
+	// │ A new release is available: v0.1.0 → v0.2.0         │
+	// │ https://github.com/you/your-app/releases/tag/v0.2.0 │
+	// ╰─────────────────────────────────────────────────────╯
+}
+
+func init() {
+	// don't skip checks when the tests run in CI, or read the user
+	// config of whoever runs them.
+	testenv.Clear("../testdata/no-config")
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package notice renders the result of a whatsnew Check as a notice for
+// users, such as "A new release is available".
+//
+// Notices are written to stderr by default, so they don't end up in
+// output piped from your application. ANSI colour is only used when
+// writing to a terminal, and honors the NO_COLOR environment variable.
+package notice
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/jbowes/whatsnew"
+)
+
+// DefaultTemplate is the text/template used for notices if no other is
+// given. Templates are executed with the *whatsnew.Result of a Check,
+// and may use the colour functions `bold`, `faint`, `green`, `yellow`
+// and `cyan`.
+const DefaultTemplate = `A new release is available: {{if .Current}}{{faint .Current}} → {{end}}{{green .Version}}
+{{- with .URL}}
+{{cyan .}}{{end}}`
+
+// Style is the visual style of a notice.
+type Style int
+
+// Styles for notices.
+const (
+	Plain Style = iota // The notice text as is.
+	Box                // The notice text drawn in a box.
+)
+
+// Color controls the use of ANSI colour in notices.
+type Color int
+
+// Colour modes for notices.
+const (
+	// Auto uses colour if the Writer is a terminal, and the NO_COLOR
+	// environment variable is not set.
+	Auto Color = iota
+	Always
+	Never
+)
+
+// Options sets optional values for writing a notice.
+type Options struct {
+	// Optional. Where to write the notice. If not provided, os.Stderr is
+	// used.
+	Writer io.Writer
+
+	// Optional. A text/template for the notice. If not provided,
+	// DefaultTemplate is used.
+	Template string
+
+	// Optional. The style of the notice. If not provided, Plain is used.
+	Style Style
+
+	// Optional. When to use colour. If not provided, Auto is used.
+	Color Color
+}
+
+// Print writes a notice for res, if it has a newer version. If res is
+// nil, or has no newer version, nothing is written. opts may be nil to
+// use the defaults.
+func Print(res *whatsnew.Result, opts *Options) error {
+	if res == nil || res.Version == "" {
+		return nil
+	}
+
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	w := opts.Writer
+	if w == nil {
+		w = os.Stderr
+	}
+
+	text := opts.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+
+	tmpl, err := template.New("notice").Funcs(funcs(useColor(opts.Color, w))).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, res); err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if opts.Style == Box {
+		lines = box(lines)
+	}
+
+	_, err = io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// useColor reports if colour should be used when writing to w.
+func useColor(c Color, w io.Writer) bool {
+	switch c {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// funcs returns the colour functions for templates. Without colour,
+// they return their argument as is.
+func funcs(color bool) template.FuncMap {
+	sgr := func(code string) func(string) string {
+		return func(s string) string {
+			if !color || s == "" {
+				return s
+			}
+
+			return "\x1b[" + code + "m" + s + "\x1b[0m"
+		}
+	}
+
+	return template.FuncMap{
+		"bold":   sgr("1"),
+		"faint":  sgr("2"),
+		"green":  sgr("32"),
+		"yellow": sgr("33"),
+		"cyan":   sgr("36"),
+	}
+}
+
+// sgrPattern matches the ANSI colour sequences added by funcs.
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// width returns the number of columns s takes in a terminal.
+func width(s string) int {
+	return utf8.RuneCountInString(sgrPattern.ReplaceAllString(s, ""))
+}
+
+// box draws a box around lines.
+func box(lines []string) []string {
+	cols := 0
+	for _, l := range lines {
+		if w := width(l); w > cols {
+			cols = w
+		}
+	}
+
+	out := []string{"╭" + strings.Repeat("─", cols+2) + "╮"}
+	for _, l := range lines {
+		out = append(out, "│ "+l+strings.Repeat(" ", cols-width(l))+" │")
+	}
+
+	return append(out, "╰"+strings.Repeat("─", cols+2)+"╯")
+}
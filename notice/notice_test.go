@@ -0,0 +1,113 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package notice_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbowes/whatsnew"
+	"github.com/jbowes/whatsnew/internal/testenv"
+	"github.com/jbowes/whatsnew/notice"
+)
+
+var res = &whatsnew.Result{
+	Current: "v1.0.0",
+	Version: "v1.1.0",
+	URL:     "https://github.com/you/your-app/releases/tag/v1.1.0",
+}
+
+func TestPrint(t *testing.T) {
+	tcs := map[string]struct {
+		opts notice.Options
+		out  string
+	}{
+		"plain": {
+			notice.Options{},
+			"A new release is available: v1.0.0 → v1.1.0\nhttps://github.com/you/your-app/releases/tag/v1.1.0\n",
+		},
+		"box": {
+			notice.Options{Style: notice.Box},
+			`╭─────────────────────────────────────────────────────╮
+│ A new release is available: v1.0.0 → v1.1.0         │
+│ https://github.com/you/your-app/releases/tag/v1.1.0 │
+╰─────────────────────────────────────────────────────╯
+`,
+		},
+		"color": {
+			notice.Options{Color: notice.Always},
+			"A new release is available: \x1b[2mv1.0.0\x1b[0m → \x1b[32mv1.1.0\x1b[0m\n\x1b[36mhttps://github.com/you/your-app/releases/tag/v1.1.0\x1b[0m\n",
+		},
+		"color box": {
+			notice.Options{Color: notice.Always, Style: notice.Box, Template: "{{bold .Version}}!"},
+			"╭─────────╮\n│ \x1b[1mv1.1.0\x1b[0m! │\n╰─────────╯\n",
+		},
+		"template": {
+			notice.Options{Template: "{{.Version}} is out.\n\nRun `brew upgrade your-app`.\n"},
+			"v1.1.0 is out.\n\nRun `brew upgrade your-app`.\n",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.opts.Writer = &buf
+
+			if err := notice.Print(res, &tc.opts); err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if buf.String() != tc.out {
+				t.Errorf("notice did not match. got:\n%q\nwant:\n%q", buf.String(), tc.out)
+			}
+		})
+	}
+}
+
+func TestPrint_noUpdate(t *testing.T) {
+	for name, res := range map[string]*whatsnew.Result{
+		"nil":       nil,
+		"no update": {Current: "v1.1.0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := notice.Print(res, &notice.Options{Writer: &buf}); err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("expected no notice. got: %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestPrint_errOnBadTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	err := notice.Print(res, &notice.Options{Writer: &buf, Template: "{{.Version"})
+	if err == nil {
+		t.Error("expected err but got none")
+	}
+}
+
+func TestPrint_noColorWhenNotTerminal(t *testing.T) {
+	testenv.Setenv(t, "NO_COLOR", "")
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "notice"))
+	if err != nil {
+		t.Fatal("couldn't set up output file")
+	}
+	defer f.Close()
+
+	if err := notice.Print(res, &notice.Options{Writer: f}); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	b, _ := os.ReadFile(f.Name())
+	if strings.Contains(string(b), "\x1b") {
+		t.Errorf("expected no colour. got: %q", b)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"path"
+
+	"github.com/jbowes/whatsnew/internal/env"
+)
+
+// skipReason returns why release checks are disabled by the environment
+// for the application in slug, or the empty string if they are not.
+//
+// Checks are disabled by `<APP>_NO_UPDATE_CHECK`, where APP is derived
+// from the slug as for DefaultCachePath, WHATSNEW_DISABLE, DO_NOT_TRACK,
+// or any of the variables set by common CI services.
+func skipReason(slug string) string {
+	var vars []string
+	if _, slug, err := parseSlug(slug); err == nil {
+		vars = append(vars, appEnv(path.Base(slug), "NO_UPDATE_CHECK"))
+	}
+	vars = append(vars, env.OptOut...)
+
+	for _, k := range vars {
+		if env.Set(k) {
+			return k + " is set"
+		}
+	}
+
+	for _, k := range env.CI {
+		if env.Set(k) {
+			return "running in CI (" + k + " is set)"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/jbowes/whatsnew/impl"
+)
+
+// Length limits, in bytes, for strings from Releasers. Longer strings
+// are truncated, except for tags, which are rejected.
+const (
+	maxTagLen  = 128
+	maxNameLen = 256
+	maxURLLen  = 2048
+	maxBodyLen = 64 << 10
+)
+
+// Release strings come from Releasers, which are not trusted: a
+// compromised repository could include terminal escape sequences in its
+// releases to change a user's terminal title, add hyperlinks, or worse.
+// Every string exposed for display is sanitised, both when fetched, and
+// when read from the cache.
+
+// sanitizeReleases returns the releases in rels with valid tags, with
+// their strings sanitised.
+func sanitizeReleases(rels []impl.Release) []impl.Release {
+	if rels == nil {
+		return nil
+	}
+
+	out := make([]impl.Release, 0, len(rels))
+	for _, rel := range rels {
+		if !validTag(rel.TagName) {
+			continue
+		}
+
+		rel.Name = cleanLine(rel.Name, maxNameLen)
+		rel.Body = cleanText(rel.Body, maxBodyLen)
+		rel.HTMLURL = cleanURL(rel.HTMLURL)
+
+		if rel.Assets != nil {
+			assets := make([]impl.Asset, len(rel.Assets))
+			for n, a := range rel.Assets {
+				a.Name = cleanLine(a.Name, maxNameLen)
+				a.BrowserDownloadURL = cleanURL(a.BrowserDownloadURL)
+				a.ContentType = cleanLine(a.ContentType, maxNameLen)
+				assets[n] = a
+			}
+			rel.Assets = assets
+		}
+
+		out = append(out, rel)
+	}
+
+	return out
+}
+
+// sanitizeRetractions returns the retractions in rets with valid
+// versions, with their rationales sanitised.
+func sanitizeRetractions(rets []impl.Retraction) []impl.Retraction {
+	if rets == nil {
+		return nil
+	}
+
+	out := make([]impl.Retraction, 0, len(rets))
+	for _, ret := range rets {
+		if !validTag(ret.Low) || !validTag(ret.High) {
+			continue
+		}
+
+		ret.Rationale = cleanText(ret.Rationale, maxNameLen*4)
+		out = append(out, ret)
+	}
+
+	return out
+}
+
+// sanitizeInfo returns a copy of i, with the strings from Releasers
+// sanitised. An invalid Version, or negative Failures, are cleared.
+func sanitizeInfo(i *impl.Info) *impl.Info {
+	ni := *i
+	if !validTag(ni.Version) {
+		ni.Version = ""
+	}
+	if ni.Failures < 0 {
+		ni.Failures = 0
+	}
+
+	ni.Name = cleanLine(ni.Name, maxNameLen)
+	ni.URL = cleanURL(ni.URL)
+	ni.Releases = sanitizeReleases(ni.Releases)
+	ni.Retractions = sanitizeRetractions(ni.Retractions)
+
+	return &ni
+}
+
+// validTag reports if tag is short enough, and only contains printable
+// ASCII bytes. Valid semver versions always are. An empty tag is valid.
+func validTag(tag string) bool {
+	if len(tag) > maxTagLen {
+		return false
+	}
+
+	for n := 0; n < len(tag); n++ {
+		if tag[n] <= ' ' || tag[n] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cleanURL sanitises a URL, which must be http or https, or is cleared.
+func cleanURL(u string) string {
+	u = cleanLine(u, maxURLLen)
+	lower := strings.ToLower(u)
+	if !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "http://") {
+		return ""
+	}
+
+	return u
+}
+
+// cleanLine sanitises text for display on a single line. Newlines and
+// tabs are replaced with spaces.
+func cleanLine(s string, limit int) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, cleanText(s, limit))
+}
+
+// cleanText sanitises text for display, removing terminal escape
+// sequences, control characters other than newlines and tabs, and
+// bidirectional text overrides, then truncating it to limit bytes.
+func cleanText(s string, limit int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	var b strings.Builder
+	rs := []rune(s)
+	for n := 0; n < len(rs); n++ {
+		r := rs[n]
+		switch {
+		case r == '\x1b' || r == '\u009b' || r == '\u009d' || r == '\u0090' || r == '\u009e' || r == '\u009f':
+			n = skipEscape(rs, n)
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case unicode.IsControl(r), isBidi(r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return truncate(b.String(), limit)
+}
+
+// skipEscape returns the index of the last rune of the escape sequence
+// starting at rs[n], which is ESC, or a C1 control that introduces a
+// sequence.
+func skipEscape(rs []rune, n int) int {
+	kind := rs[n]
+	if kind == '\x1b' {
+		if n+1 >= len(rs) {
+			return n
+		}
+
+		n++
+		switch rs[n] {
+		case '[':
+			kind = '\u009b' // CSI
+		case ']':
+			kind = '\u009d' // OSC
+		case 'P', '^', '_':
+			kind = '\u0090' // DCS, PM, APC; all end with ST
+		default:
+			return n // a two character sequence
+		}
+	}
+
+	for n++; n < len(rs); n++ {
+		r := rs[n]
+		switch {
+		case kind == '\u009b':
+			// Parameter and intermediate bytes, then a final byte.
+			if r >= 0x40 && r <= 0x7e {
+				return n
+			}
+			if r < 0x20 || r > 0x7e {
+				return n - 1 // malformed; drop what was read.
+			}
+		case r == '\a' || r == '\u009c': // BEL or ST
+			return n
+		case r == '\x1b' && n+1 < len(rs) && rs[n+1] == '\\': // ESC \ is ST
+			return n + 1
+		}
+	}
+
+	return n
+}
+
+// isBidi reports if r is a bidirectional text override or isolate,
+// which can make text display differently than it reads.
+func isBidi(r rune) bool {
+	return r >= '\u202a' && r <= '\u202e' || r >= '\u2066' && r <= '\u2069' || r == '\u200e' || r == '\u200f'
+}
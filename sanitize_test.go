@@ -0,0 +1,152 @@
+// Copyright (c) 2021 James Bowes. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whatsnew_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbowes/whatsnew"
+	"github.com/jbowes/whatsnew/impl"
+)
+
+func TestCheck_sanitizesBody(t *testing.T) {
+	tcs := map[string]struct {
+		in  string
+		out string
+	}{
+		"plain":           {"Fixes.\n\n- one\n\t- two", "Fixes.\n\n- one\n\t- two"},
+		"crlf":            {"one\r\ntwo\rthree", "one\ntwothree"},
+		"sgr":             {"\x1b[1;31mred\x1b[0m text", "red text"},
+		"c1 csi":          {"\u009b2Jcleared", "cleared"},
+		"title":           {"\x1b]0;pwned\x07title", "title"},
+		"hyperlink":       {"\x1b]8;;https://evil.example\x1b\\click\x1b]8;;\x1b\\", "click"},
+		"c1 osc":          {"\u009d0;pwned\u009ctitle", "title"},
+		"dcs":             {"\x1bPq#0;2;0;0;0\x1b\\after", "after"},
+		"two char escape": {"\x1bcreset", "reset"},
+		"unterminated":    {"before\x1b]0;pwned", "before"},
+		"malformed csi":   {"\x1b[12\nnext", "\nnext"},
+		"controls":        {"bell\a back\bspace\x00null\x7fdel", "bell backspacenulldel"},
+		"bidi":            {"admin\u202e\u2066txt.exe", "admintxt.exe"},
+		"invalid utf8":    {"bad\xffbyte", "bad�byte"},
+		"unicode":         {"emoji 🎉 and ünïcödé", "emoji 🎉 and ünïcödé"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  "v1.0.0",
+				Cacher:   &testCacher{info: &impl.Info{}},
+				Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1", Body: tc.in}}},
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if res.Release.Body != tc.out {
+				t.Errorf("body did not match. got: %q, want: %q", res.Release.Body, tc.out)
+			}
+		})
+	}
+}
+
+func TestCheck_sanitizesRelease(t *testing.T) {
+	cacher := &testCacher{info: &impl.Info{}}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:       "v1.0.0",
+		CacheReleases: true,
+		Cacher:        cacher,
+		Releaser: &testReleaser{releases: []impl.Release{
+			{TagName: "v9.9.9\x1b[2J"},
+			{TagName: "v9.9.8 "},
+			{TagName: "v9.9.7-" + strings.Repeat("a", 200)},
+			{
+				TagName: "v1.0.1",
+				Name:    "Evil\n\x1b[31mred\x1b[0m\t" + strings.Repeat("a", 1000),
+				HTMLURL: "javascript:alert(1)",
+				Assets: []impl.Asset{{
+					Name:               "app\x1b]0;pwned\x07.tar.gz",
+					BrowserDownloadURL: "https://example.com/app\x00.tar.gz",
+				}},
+			},
+		}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if res.Version != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res.Version, "v1.0.1")
+	}
+
+	rel := res.Release
+	if want := "Evil red " + strings.Repeat("a", 247); rel.Name != want {
+		t.Errorf("name did not match. got: %q, want: %q", rel.Name, want)
+	}
+	if rel.HTMLURL != "" || res.URL != "" {
+		t.Errorf("expected url to be cleared. got: %q", rel.HTMLURL)
+	}
+	if a := rel.Assets[0]; a.Name != "app.tar.gz" || a.BrowserDownloadURL != "https://example.com/app.tar.gz" {
+		t.Errorf("asset did not match. got: %+v", a)
+	}
+
+	if len(cacher.set.Releases) != 1 || cacher.set.Name != rel.Name {
+		t.Errorf("expected sanitised cache. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_sanitizesCache(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.0.0",
+		Cacher: &testCacher{info: &impl.Info{
+			CheckTime: time.Now(),
+			Version:   "v1.0.1",
+			Name:      "\x1b]0;pwned\x07patch",
+			URL:       "https://example.com/\x1b[2J",
+			Retractions: []impl.Retraction{
+				{Low: "v1.0.0", High: "v1.0.0", Rationale: "\x1b[5mbroken\x1b[0m"},
+			},
+		}},
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if res.Release.Name != "patch" || res.URL != "https://example.com/" {
+		t.Errorf("expected sanitised release. got: %+v", res.Release)
+	}
+	if res.Retraction == nil || res.Retraction.Rationale != "broken" {
+		t.Errorf("expected sanitised retraction. got: %+v", res.Retraction)
+	}
+}
+
+func TestCheck_rejectsCachedVersion(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.0.0",
+		Cacher: &testCacher{info: &impl.Info{
+			CheckTime: time.Now(),
+			Version:   "v1.0.1\x1b[2J",
+		}},
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	if v, _ := fut.Get(); v != "" {
+		t.Errorf("expected no version. got: %q", v)
+	}
+}
@@ -10,13 +10,19 @@
 // known releases from public GitHub repos. If you need to modify this
 // behaviour, see the `impl` subpackage for details on how to provide
 // your own impl.Cacher or impl.Releaser.
+//
+// To show the result of a Check to users, see the `notice` subpackage.
 package whatsnew
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jbowes/semver"
 
@@ -31,6 +37,27 @@ var ErrMisconfiguredOptions = errors.New("invalid options provided")
 // releases if no override is given. It is one week.
 const DefaultFrequency = 7 * 24 * time.Hour
 
+// Values controlling release check leases, for Cachers that implement
+// impl.Leaser. A lease is held for the check Timeout, or defaultLeaseTTL
+// if there is none. Processes without the lease poll for leaseWait for
+// the result of the check.
+const (
+	defaultLeaseTTL = 30 * time.Second
+	leaseWait       = time.Second
+	leasePoll       = 50 * time.Millisecond
+)
+
+// Limits on the releases kept in the cache when Options.CacheReleases is
+// set. Bodies longer than maxCachedBody bytes are truncated.
+const (
+	maxCachedReleases = 50
+	maxCachedBody     = 8 << 10
+)
+
+// failureBackoff is how long to wait before checking again after a
+// single failed check.
+const failureBackoff = time.Minute
+
 // Timeout values used as options to Check, controlling how long the
 // Check is allowed to run.
 const (
@@ -38,9 +65,70 @@ const (
 	NoTimeout      = time.Duration(-1)
 )
 
+// Result holds the details of a completed Check.
+type Result struct {
+	// Version is the newer version found, or the empty string if no
+	// update is available.
+	Version string
+
+	// Semver is the parsed Version, or nil if no update is available.
+	Semver *semver.Version
+
+	// Current is the running version, from Options.Version.
+	Current string
+
+	// Skipped is why the Check was skipped, if the environment opts out
+	// of release checks, such as when running in CI, or if DO_NOT_TRACK
+	// is set, or if they are disabled in the user config file. Skipped
+	// Checks report no update, without using the network or cache.
+	Skipped string
+
+	// Cached is true if the result came from the cache, rather than
+	// from a release check over the network.
+	Cached bool
+
+	// CheckTime is when the releases were last checked over the network.
+	// For cached results, this is the time of the previous check.
+	CheckTime time.Time
+
+	URL         string    // A link to the release, if known.
+	PublishedAt time.Time // When the release was published, if known.
+
+	// Release is the release for Version, or nil if no update is
+	// available. Cached results only hold the release's TagName, Name,
+	// HTMLURL and PublishedAt, unless Options.CacheReleases is set.
+	Release *impl.Release
+
+	// Retraction is set if the running version has been retracted by its
+	// publisher, even if no update is available. Retractions are only
+	// known if the Releaser implements impl.Retracter.
+	Retraction *impl.Retraction
+
+	// Upgraded is true if Options.Version is newer than the version that
+	// last ran a Check with the same cache, such as after the program was
+	// upgraded. It is only reported once per upgrade. Previous is the
+	// version that last ran. See Future.UpgradeNotes for what changed.
+	Upgraded bool
+	Previous string
+
+	// ConfigErr is set if the user config file could not be read, or is
+	// invalid. Invalid configs are not used.
+	ConfigErr error
+
+	// CacheErr is set if the cache was corrupt, and the check started
+	// fresh. It is an *impl.CorruptCacheError, which records where the
+	// corrupt cache was moved, if it was.
+	CacheErr error
+}
+
 type result struct {
-	v   string
+	res *Result
 	err error
+
+	// Used for the Changelog.
+	opts *Options
+	cur  *semver.Version
+	rels []impl.Release // the releases fetched, or cached.
 }
 
 // Future holds the future results from a call to Check.
@@ -49,25 +137,77 @@ type Future struct {
 	r *result
 }
 
+// Result returns the detailed results from a call to Check. Check runs
+// in its own goroutine; Result will block waiting for the goroutine to
+// complete.
+//
+// If err is nil, a non-nil Result is always returned.
+func (f *Future) Result() (*Result, error) {
+	if f.r == nil {
+		f.r = <-f.c
+	}
+
+	return f.r.res, f.r.err
+}
+
 // Get returns the results from a call to Check. Check runs in its own
 // goroutine; Get will block waiting for the goroutine to complete.
 //
 // If an updated version is detected, that version string is returned.
 // If no update is found, the empty string is returned.
 func (f *Future) Get() (string, error) {
-	if f.r == nil {
-		f.r = <-f.c
+	res, err := f.Result()
+	if res == nil {
+		return "", err
 	}
 
-	return f.r.v, f.r.err
+	return res.Version, err
 }
 
 // Options sets both required and optional values for running a Check.
 type Options struct {
-	Slug    string // The GitHub repository slug, eg `jbowes/whatsnew`
-	Cache   string // A full file path to store the cache. Should end in `.json`
+	Slug    string // The GitHub repository slug, eg `jbowes/whatsnew`, or its URL.
 	Version string // The current semver version of the program to check.
 
+	// Optional. A full file path to store the cache. Should end in
+	// `.json`. If not provided, DefaultCachePath is used. If there is no
+	// default path, or it is not writable, results are only cached in
+	// memory. A `.lock` file is kept next to the cache, and a `.lease`
+	// file while a check runs; see impl.FileCacher.
+	Cache string
+
+	// Optional. A full file path to a cache shared by several
+	// applications, eg `~/.cache/yourcorp/updates.json`. Each
+	// application's results are stored under CacheKey. Use either Cache
+	// or SharedCache.
+	SharedCache string
+
+	// Optional. The key for this application's results in SharedCache.
+	// If not provided, the repository from Slug is used, as `owner/repo`
+	// for github.com and `host/owner/repo` otherwise, or the ID of the
+	// Releaser if it implements impl.Identifier.
+	CacheKey string
+
+	// Optional. The base URL of the GitHub API, for GitHub Enterprise
+	// Server, eg `https://ghe.example.com/api/v3`. If not provided, it is
+	// taken from the host in Slug, or the GITHUB_API_URL environment
+	// variable. Otherwise, DefaultGitHubAPIURL is used.
+	GitHubAPIURL string
+
+	// Optional. A token used to access GitHub. If not provided, a token
+	// is discovered from the environment, GitHub CLI configuration, or
+	// .netrc, as described in impl.GitHubToken.
+	GitHubToken string
+
+	// Optional. The base URL of a Gitea or Forgejo instance hosting Slug,
+	// eg `https://codeberg.org`. If set, releases are fetched from it
+	// rather than from GitHub.
+	GiteaURL string
+
+	// Optional. A token used to access GiteaURL, for private
+	// repositories.
+	GiteaToken string
+
 	// Optional. Controls how often to run a release check.
 	// If not provided, DefaultFrequency is used.
 	Frequency time.Duration
@@ -79,43 +219,120 @@ type Options struct {
 	// may further restrict the deadline with the provided context.
 	Timeout time.Duration
 
+	// Optional. Keep the newest releases, with their names, dates and
+	// truncated bodies, in the cache, so they are available without
+	// network access, such as when the releases have not changed.
+	CacheReleases bool
+
+	// Optional. Flags to modify prerelease behaviour. If not provided,
+	// prereleases are ignored.
+	Flags Flag
+
+	// Optional. A full file path to a user config file, described in
+	// UserConfig. If not provided, DefaultConfigPath is used. A missing
+	// file is not an error.
+	ConfigPath string
+
 	// Slots to override cacher and Releaser
 	Cacher   impl.Cacher   // If provided, Cache is ignored.
 	Releaser impl.Releaser // If provided, Slug is ignored.
-}
-
-/* TODO: prerelease flags
- Flags to modify prelease etc behaviour
-	Flags Flag
 
+	// Set from the user config file.
+	disabled  string            // why checks are disabled, if they are.
+	ignore    []*semver.Version // versions to never report.
+	configErr error
+}
 
+// Flag modifies which releases are considered by a Check.
 type Flag byte
 
+// NoFlags uses the default behaviour. Prereleases (either marked as such
+// by the Releaser, or with a semver prerelease component) are ignored.
+const NoFlags Flag = 0
+
+// Flags controlling how prereleases are handled. Flags may be combined.
 const (
-	NoFlags        Flag = 0
+	// SamePrerelease reports newer prereleases of the same version when
+	// running a prerelease. For example, v2.0.0-beta.4 is reported when
+	// running v2.0.0-beta.3, but v2.1.0-beta.1 is not.
 	SamePrerelease Flag = 1 << iota
+
+	// AcrossPrerelease reports any newer prerelease when running a
+	// prerelease. For example, v2.1.0-alpha.1 is reported when running
+	// v2.0.0-beta.3.
 	AcrossPrerelease
+
+	// IntoPrerelease reports newer prereleases even when running a
+	// stable release, opting users into prereleases.
 	IntoPrerelease
 )
-*/
 
-func (o *Options) resolve() error {
+// allows reports if the release rel is eligible for update notices
+// when running cur, given the flags in f.
+func (f Flag) allows(cur, rel *semver.Version, marked bool) bool {
+	if !marked && rel.Prerelease() == "" {
+		return true // stable releases are always eligible
+	}
+
+	switch {
+	case f&IntoPrerelease != 0:
+		return true
+	case cur == nil || cur.Prerelease() == "":
+		return false // running a stable release.
+	case f&AcrossPrerelease != 0:
+		return true
+	case f&SamePrerelease != 0:
+		return core(cur) == core(rel)
+	}
+
+	return false
+}
+
+// core returns the major.minor.patch portion of v.
+func core(v *semver.Version) string {
+	s := v.String()
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	return s
+}
+
+// validate returns an error if Options conflict, or Slug is invalid.
+// It does not read credentials or touch the filesystem, so it is safe
+// to run when checks are skipped.
+func (o *Options) validate() error {
 	if o.Cacher != nil && o.Cache != "" {
 		return fmt.Errorf("cache and cacher set: %w", ErrMisconfiguredOptions)
 	}
 
+	if o.SharedCache != "" && (o.Cacher != nil || o.Cache != "") {
+		return fmt.Errorf("shared cache and cache or cacher set: %w", ErrMisconfiguredOptions)
+	}
+
 	if o.Releaser != nil && o.Slug != "" {
 		return fmt.Errorf("releaser and slug set: %w", ErrMisconfiguredOptions)
 	}
 
-	if o.Cacher == nil {
-		o.Cacher = &impl.FileCacher{Path: o.Cache}
+	if o.Releaser != nil && o.GiteaURL != "" {
+		return fmt.Errorf("releaser and gitea url set: %w", ErrMisconfiguredOptions)
 	}
 
 	if o.Releaser == nil {
-		o.Releaser = &impl.GitHubReleaser{URL: fmt.Sprintf("https://api.github.com/repos/%s/releases", o.Slug)}
+		if _, _, err := parseSlug(o.Slug); err != nil {
+			return err
+		}
 	}
 
+	if _, ok := o.Releaser.(impl.Identifier); o.SharedCache != "" && o.CacheKey == "" && o.Slug == "" && !ok {
+		return fmt.Errorf("no key for shared cache: %w", ErrMisconfiguredOptions)
+	}
+
+	return nil
+}
+
+// configure sets default Options, then applies the user config file.
+func (o *Options) configure() {
 	if o.Frequency == 0 {
 		o.Frequency = DefaultFrequency
 	}
@@ -124,7 +341,57 @@ func (o *Options) resolve() error {
 		o.Timeout = DefaultTimeout
 	}
 
-	return nil
+	config := o.ConfigPath
+	if config == "" {
+		config, _ = DefaultConfigPath(o.Slug)
+	}
+	if config != "" {
+		o.applyConfig(config)
+	}
+}
+
+// resolve sets the Releaser and Cacher, if they are not set, from the
+// validated Options. This may read credentials, and create the cache
+// directory.
+func (o *Options) resolve() {
+	if o.Releaser == nil {
+		host, slug, _ := parseSlug(o.Slug)
+
+		if o.GiteaURL != "" {
+			o.Releaser = &impl.GiteaReleaser{BaseURL: o.GiteaURL, Repo: slug, Token: o.GiteaToken}
+		} else {
+			api := gitHubAPIURL(o.GitHubAPIURL, host)
+
+			token := o.GitHubToken
+			if token == "" {
+				token = impl.GitHubToken(gitHubHost(api))
+			}
+
+			o.Releaser = &impl.GitHubReleaser{
+				URL:   fmt.Sprintf("%s/repos/%s/releases", api, slug),
+				Token: token,
+			}
+		}
+	}
+
+	switch {
+	case o.Cacher != nil:
+	case o.SharedCache != "":
+		key := o.CacheKey
+		if key == "" && o.Slug != "" {
+			host, slug, _ := parseSlug(o.Slug)
+			key = slugKey(host, slug)
+		}
+		if id, ok := o.Releaser.(impl.Identifier); ok && key == "" {
+			key = id.ID()
+		}
+
+		o.Cacher = &impl.KeyedFileCacher{Path: o.SharedCache, Key: key}
+	case o.Cache != "":
+		o.Cacher = &impl.FileCacher{Path: o.Cache}
+	default:
+		o.Cacher = defaultCacher(o.Slug)
+	}
 }
 
 // Check checks github for a newer release of the configured application.
@@ -133,99 +400,436 @@ func (o *Options) resolve() error {
 //
 // It returns a Future. After your application's main work is done,
 // call Get() on the future to get the result and error.
+//
+// Users may opt out of release checks by setting WHATSNEW_DISABLE,
+// DO_NOT_TRACK, or `<APP>_NO_UPDATE_CHECK`, where APP is derived from
+// the Slug as for DefaultCachePath. Checks are also skipped when
+// running in CI. See Result.Skipped. Users may also disable checks, or
+// change how they run, in a config file; see UserConfig.
 func Check(ctx context.Context, opts *Options) *Future {
 	c := make(chan *result)
 	f := Future{c: c}
 
 	go func() {
-		r := result{}
-		r.v, r.err = doWork(ctx, opts)
-		c <- &r
+		c <- doWork(ctx, opts)
 	}()
 
 	return &f
 }
 
-// TODO: return if this is a new check or not? could be useful for less spammy
-// update notice.
-func doWork(ctx context.Context, opts *Options) (string, error) {
-	if err := opts.resolve(); err != nil {
-		return "", err
+func doWork(ctx context.Context, opts *Options) *result {
+	if err := opts.validate(); err != nil {
+		return &result{err: err}
+	}
+
+	// Check for opt outs before resolving the Releaser and Cacher, so
+	// skipped checks don't read credentials or write to disk.
+	reason := skipReason(opts.Slug)
+	if reason == "" {
+		opts.configure()
+		reason = opts.disabled
+	}
+	if reason != "" {
+		return &result{res: &Result{Current: opts.Version, Skipped: reason, ConfigErr: opts.configErr}, opts: opts}
 	}
 
+	opts.resolve()
+
 	if opts.Timeout > 0 {
 		var cancel func()
 		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
 		defer cancel()
 	}
 
+	res := Result{Cached: true, Current: opts.Version, ConfigErr: opts.configErr}
+
 	i, err := opts.Cacher.Get(ctx)
+	var cerr *impl.CorruptCacheError
+	if errors.As(err, &cerr) {
+		res.CacheErr = cerr
+	}
 	if err != nil {
 		i = &impl.Info{}
 	}
-	iHasV, iVer, _ := parseV(i.Version)
+	i = sanitizeInfo(i)
 
 	now := time.Now()
+	_, optVer, _ := parseV(opts.Version)
+
+	prevRun := i.LastRunVersion
+	res.CheckTime = i.CheckTime
+	var rels []impl.Release
+	// Without cached releases, a latest chosen with other Flags or
+	// ignored versions can only be replaced by checking again.
+	reselect := i.Selection != opts.selection() && i.Releases == nil
+	if (reselect || now.Sub(i.CheckTime) >= opts.Frequency) && !now.Before(i.RetryAfter) {
+		var checked bool
+		i, rels, checked = check(ctx, opts, i, now, optVer)
+		res.Cached = !checked
+		res.CheckTime = i.CheckTime
+	}
 
-	optHasV, optVer, _ := parseV(opts.Version)
-	nextVer := optVer
-	nextHasV := optHasV
-	if now.Sub(i.CheckTime) < opts.Frequency {
-		nextVer = iVer
-		nextHasV = iHasV
-	} else {
-		rels, etag, err := opts.Releaser.Get(ctx, i.Etag)
-		if err != nil {
-			// If we error, fall back to possibly using the value from the store
-			nextVer = iVer
-			nextHasV = iHasV
-		} else if len(rels) == 0 {
-			// Cached result. refresh the checktime and store.
-			_ = opts.Cacher.Set(ctx, &impl.Info{
-				CheckTime: now,
-				Etag:      etag,
-				Version:   i.Version,
-			})
-
-			nextVer = iVer
-			nextHasV = iHasV
-		} else {
-			// find the biggest non-prerelease version in releases.
-			// TODO: could look at more than the first page. would only matter
-			// for concurrent patch releases etc.
-			var newVer *semver.Version
-			newHasV := ""
-			for _, rel := range rels {
-				hv, pv, err := parseV(rel.TagName)
-				switch {
-				case err != nil: // not a valid semver tag
-				case rel.Draft:
-				case rel.Prerelease || pv.Prerelease() != "":
-				case newVer.Compare(pv) < 0:
-					newVer = pv
-					newHasV = hv
-				}
-			}
+	if prevRun != "" && prevRun != opts.Version && opts.Version != "" {
+		_, prev, err := parseV(prevRun)
+		if err == nil && optVer != nil && prev.Compare(optVer) < 0 {
+			res.Upgraded = true
+			res.Previous = prevRun
+		}
+
+		// Save the running version, so the upgrade is reported once, if
+		// it wasn't saved with the check.
+		if i.LastRunVersion != opts.Version && ctx.Err() == nil {
+			ni := *i
+			ni.LastRunVersion = opts.Version
+			_ = opts.Cacher.Set(ctx, &ni)
+			i = &ni
+		}
+	}
+
+	r := &result{res: &res, opts: opts, cur: optVer, rels: rels}
+	if len(rels) == 0 {
+		r.rels = i.Releases
+	}
+
+	res.Retraction = retracted(i.Retractions, optVer)
+
+	// The cached latest may have been chosen for another running version,
+	// Flags, or ignored versions, so choose again from the cached
+	// releases, if there are any.
+	rel := latest(i)
+	switch {
+	case len(rels) != 0:
+		rel = newest(rels, opts, optVer, i.Retractions)
+	case len(i.Releases) != 0:
+		rel = newest(i.Releases, opts, optVer, i.Retractions)
+	default:
+		if _, v, err := parseV(rel.TagName); err == nil && !opts.eligible(rel, v, optVer, i.Retractions) {
+			rel = nil
+		}
+	}
+
+	if rel == nil {
+		return r
+	}
+
+	_, v, err := parseV(rel.TagName)
+	if err != nil || optVer.Compare(v) >= 0 {
+		return r
+	}
+
+	res.Version = rel.TagName
+	res.Semver = v
+	res.URL = rel.HTMLURL
+	res.PublishedAt = rel.PublishedAt
+	res.Release = rel
+
+	return r
+}
 
-			// TODO: make sure newVer is set
-			_ = opts.Cacher.Set(ctx, &impl.Info{
-				CheckTime: now,
-				Etag:      etag,
-				Version:   newHasV + newVer.String(), // we store the latest from the remote ignoring what's installed.
-			})
+// check checks for new releases over the network, and saves the
+// results. It returns the updated Info, the releases fetched, if any,
+// and whether this call ran the check.
+//
+// If the Cacher is an impl.Leaser, and another process holds the lease,
+// check waits briefly for that process's result instead. If another
+// process saved its result before the lease was taken, that is used.
+func check(ctx context.Context, opts *Options, i *impl.Info, now time.Time, optVer *semver.Version) (*impl.Info, []impl.Release, bool) {
+	if l, ok := opts.Cacher.(impl.Leaser); ok {
+		ttl := opts.Timeout
+		if ttl <= 0 {
+			ttl = defaultLeaseTTL
+		}
+
+		release, held, err := l.Lease(ctx, ttl)
+		switch {
+		case err != nil: // check without the lease.
+		case held:
+			defer release()
 
-			if nextVer.Compare(newVer) < 1 {
-				nextVer = newVer
-				nextHasV = newHasV
+			// Another process may have saved its check since i was read.
+			if ni, err := opts.Cacher.Get(ctx); err == nil && advanced(ni, i) {
+				return sanitizeInfo(ni), nil, false
 			}
+		default:
+			return waitForCheck(ctx, opts.Cacher, i), nil, false
+		}
+	}
+
+	// Without cached releases, an unchanged response can't be reused,
+	// nor can one for a latest chosen with other options.
+	etag := i.Etag
+	if i.Releases == nil && (opts.CacheReleases || i.Selection != opts.selection()) {
+		etag = ""
+	}
+
+	rels, etag, err := opts.Releaser.Get(ctx, etag)
+
+	// Copy to keep any other cached values, and record the running
+	// version.
+	ni := *i
+	if opts.Version != "" {
+		ni.LastRunVersion = opts.Version
+	}
+
+	var rle *impl.RateLimitError
+	switch {
+	case errors.As(err, &rle):
+		// Save when we may try again, and use the value from the store.
+		// Don't trust the source to wait longer than Frequency, or
+		// DefaultFrequency when always checking.
+		limit := opts.Frequency
+		if limit <= 0 {
+			limit = DefaultFrequency
+		}
+		ni.RetryAfter = rle.RetryAfter
+		if ni.RetryAfter.After(now.Add(limit)) {
+			ni.RetryAfter = now.Add(limit)
+		}
+		_ = opts.Cacher.Set(ctx, &ni)
+
+		return &ni, nil, false
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		return i, nil, false
+	case err != nil:
+		// Back off before trying again, and use the value from the store.
+		ni.Failures++
+		ni.LastFailure = now
+		ni.RetryAfter = now.Add(backoff(ni.Failures, opts.Frequency))
+		_ = opts.Cacher.Set(ctx, &ni)
+
+		return &ni, nil, false
+	}
+
+	ni.CheckTime = now
+	ni.Etag = etag
+	ni.RetryAfter = time.Time{}
+	ni.Failures = 0
+
+	// An empty list is a cached result, so the cached retractions still
+	// apply. Otherwise, get the retractions first, as retracted versions
+	// are never the newest, as with the go command.
+	if r, ok := opts.Releaser.(impl.Retracter); ok && len(rels) != 0 {
+		if rets, err := r.Retractions(ctx); err == nil {
+			ni.Retractions = sanitizeRetractions(rets)
+		}
+	}
+
+	// Store the newest eligible release for the running version. It is
+	// checked again when read from the cache, as the version and Flags
+	// may change.
+	if len(rels) != 0 {
+		rels = sanitizeReleases(rels)
+		setLatest(&ni, newest(rels, opts, optVer, ni.Retractions))
+		ni.Selection = opts.selection()
+
+		if opts.CacheReleases {
+			ni.Releases = cacheable(rels)
+		}
+	}
+
+	if !opts.CacheReleases {
+		ni.Releases = nil
+	}
+
+	_ = opts.Cacher.Set(ctx, &ni)
+
+	return &ni, rels, true
+}
+
+// waitForCheck waits for another process to save the results of its
+// release check. It returns the updated Info, or i if nothing was saved
+// before leaseWait passed.
+func waitForCheck(ctx context.Context, c impl.Cacher, i *impl.Info) *impl.Info {
+	timeout := time.After(leaseWait)
+	for {
+		select {
+		case <-ctx.Done():
+			return i
+		case <-timeout:
+			return i
+		case <-time.After(leasePoll):
+		}
+
+		ni, err := c.Get(ctx)
+		if err != nil {
+			continue
+		}
+
+		if advanced(ni, i) {
+			return sanitizeInfo(ni)
+		}
+	}
+}
+
+// advanced reports if ni records a release check, failure, or rate limit
+// since i.
+func advanced(ni, i *impl.Info) bool {
+	return ni.CheckTime.After(i.CheckTime) || ni.LastFailure.After(i.LastFailure) || !ni.RetryAfter.Equal(i.RetryAfter)
+}
+
+// backoff returns how long to wait before checking again after the
+// given number of consecutive failures. The delay doubles with each
+// failure, up to limit, and is jittered so many clients don't retry in
+// lockstep. If limit is not positive, as when always checking, the
+// delay after a single failure is used.
+func backoff(failures int, limit time.Duration) time.Duration {
+	if failures < 1 {
+		failures = 1
+	}
+	if limit <= 0 {
+		limit = failureBackoff
+	}
+
+	d := limit
+	if failures < 32 {
+		if exp := failureBackoff << (failures - 1); exp > 0 && exp < limit {
+			d = exp
 		}
 	}
 
-	if optVer.Compare(nextVer) >= 0 {
-		return "", nil
+	// Wait between half and all of the delay.
+	half := d / 2
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return half + time.Duration(rnd.Int63n(int64(d-half)+1))
+}
+
+// setLatest saves the details of the latest release rel in i.
+// If rel is nil, the details are cleared.
+func setLatest(i *impl.Info, rel *impl.Release) {
+	if rel == nil {
+		rel = &impl.Release{}
+	}
+
+	i.Version = rel.TagName
+	i.Name = rel.Name
+	i.URL = rel.HTMLURL
+	i.PublishedAt = rel.PublishedAt
+	i.Prerelease = rel.Prerelease
+}
+
+// latest returns the details of the latest release saved in i,
+// including its cached release, if there is one.
+func latest(i *impl.Info) *impl.Release {
+	for n := range i.Releases {
+		if i.Releases[n].TagName == i.Version {
+			return &i.Releases[n]
+		}
+	}
+
+	return &impl.Release{
+		TagName:     i.Version,
+		Name:        i.Name,
+		HTMLURL:     i.URL,
+		PublishedAt: i.PublishedAt,
+		Prerelease:  i.Prerelease,
+	}
+}
+
+// cacheable returns the newest non-draft releases in rels with semver
+// tags, newest first, up to maxCachedReleases. Assets are dropped, and
+// bodies truncated to maxCachedBody.
+func cacheable(rels []impl.Release) []impl.Release {
+	type versioned struct {
+		rel impl.Release
+		v   *semver.Version
+	}
+
+	var vs []versioned
+	for _, rel := range rels {
+		_, v, err := parseV(rel.TagName)
+		if err != nil || rel.Draft {
+			continue
+		}
+
+		rel.Assets = nil
+		rel.Body = truncate(rel.Body, maxCachedBody)
+		vs = append(vs, versioned{rel, v})
+	}
+
+	sort.SliceStable(vs, func(a, b int) bool { return vs[a].v.Compare(vs[b].v) > 0 })
+	if len(vs) > maxCachedReleases {
+		vs = vs[:maxCachedReleases]
+	}
+
+	out := make([]impl.Release, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, v.rel)
+	}
+
+	return out
+}
+
+// truncate shortens s to at most n bytes, without splitting a UTF-8
+// encoded rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
 
-	return nextHasV + nextVer.String(), nil
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// retracted returns the retraction covering cur, or nil if there is none.
+func retracted(rets []impl.Retraction, cur *semver.Version) *impl.Retraction {
+	if cur == nil {
+		return nil
+	}
+
+	for i, ret := range rets {
+		_, low, lowErr := parseV(ret.Low)
+		_, high, highErr := parseV(ret.High)
+		if lowErr == nil && highErr == nil && low.Compare(cur) <= 0 && cur.Compare(high) <= 0 {
+			return &rets[i]
+		}
+	}
+
+	return nil
+}
+
+// newest returns the biggest eligible version in rels, or nil if there
+// are none. Versions ignored in the user config, or retracted in rets,
+// are not eligible.
+func newest(rels []impl.Release, opts *Options, cur *semver.Version, rets []impl.Retraction) *impl.Release {
+	var newRel *impl.Release
+	var newVer *semver.Version
+	for i, rel := range rels {
+		_, pv, err := parseV(rel.TagName)
+		switch {
+		case err != nil: // not a valid semver tag
+		case !opts.eligible(&rels[i], pv, cur, rets):
+		case newVer.Compare(pv) < 0:
+			newRel = &rels[i]
+			newVer = pv
+		}
+	}
+
+	return newRel
+}
+
+// selection describes the Options and user config used to choose the
+// latest release, to save with it. It is empty for the defaults.
+func (o *Options) selection() string {
+	if o.Flags == NoFlags && len(o.ignore) == 0 {
+		return ""
+	}
+
+	ignore := make([]string, 0, len(o.ignore))
+	for _, v := range o.ignore {
+		ignore = append(ignore, v.String())
+	}
+	sort.Strings(ignore)
+
+	return fmt.Sprintf("flags=%d;ignore=%s", o.Flags, strings.Join(ignore, ","))
+}
+
+// eligible reports if rel, with version v, may be reported when running
+// cur, given the retractions in rets.
+func (o *Options) eligible(rel *impl.Release, v, cur *semver.Version, rets []impl.Retraction) bool {
+	return !rel.Draft && o.Flags.allows(cur, v, rel.Prerelease) && !o.ignored(v) && retracted(rets, v) == nil
 }
 
 func parseV(s string) (string, *semver.Version, error) {
@@ -7,29 +7,81 @@ package whatsnew_test
 import (
 	"context"
 	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jbowes/whatsnew"
 	"github.com/jbowes/whatsnew/impl"
+	"github.com/jbowes/whatsnew/internal/testenv"
 )
 
 type testCacher struct {
 	info *impl.Info
 	err  error
+	set  *impl.Info // the last Info passed to Set
 }
 
 func (t *testCacher) Get(context.Context) (*impl.Info, error) { return t.info, t.err }
-func (testCacher) Set(context.Context, *impl.Info) error      { return nil }
+func (t *testCacher) Set(_ context.Context, i *impl.Info) error {
+	t.set = i
+	return nil
+}
+
+// testLeaser is a testCacher that implements impl.Leaser. After
+// the lease is taken or refused, Get returns the updated Info, as if
+// another process had just saved its check.
+type testLeaser struct {
+	testCacher
+	held     bool // if the lease is held by someone else
+	released bool
+	updated  *impl.Info
+}
+
+func (t *testLeaser) Lease(context.Context, time.Duration) (func(), bool, error) {
+	if t.updated != nil {
+		t.info = t.updated
+	}
+
+	if t.held {
+		return nil, false, nil
+	}
+
+	return func() { t.released = true }, true, nil
+}
 
 type testReleaser struct {
-	releases []impl.Release
-	err      error
+	releases    []impl.Release
+	err         error
+	etag        string // the etag passed to Get
+	notModified bool   // if set, return no releases when given an etag
 }
 
-func (t *testReleaser) Get(context.Context, string) ([]impl.Release, string, error) {
+func (t *testReleaser) Get(_ context.Context, etag string) ([]impl.Release, string, error) {
+	t.etag = etag
+	if t.notModified && etag != "" {
+		return nil, etag, t.err
+	}
 	return t.releases, "some-etag", t.err
 }
 
+type testRetracter struct {
+	testReleaser
+	retractions []impl.Retraction
+	err         error
+	calls       int
+}
+
+func (t *testRetracter) Retractions(context.Context) ([]impl.Retraction, error) {
+	t.calls++
+	return t.retractions, t.err
+}
+
 func TestCheck(t *testing.T) {
 	ctx := context.Background()
 	tcs := map[string]struct {
@@ -100,74 +152,1872 @@ func TestCheck(t *testing.T) {
 	}
 }
 
-func TestCheck_fallsBackToCacheOnReleaserError(t *testing.T) {
+func TestCheck_prereleaseFlags(t *testing.T) {
+	ctx := context.Background()
+	tcs := map[string]struct {
+		version  string
+		flags    whatsnew.Flag
+		releases []impl.Release
+		out      string
+	}{
+		"no flags from prerelease skips prerelease": {
+			version:  "v2.0.0-beta.3",
+			releases: []impl.Release{{TagName: "v2.0.0-beta.4"}},
+			out:      "",
+		},
+		"no flags from prerelease finds stable": {
+			version: "v2.0.0-beta.3",
+			releases: []impl.Release{
+				{TagName: "v2.0.0-beta.4"},
+				{TagName: "v2.0.0"},
+			},
+			out: "v2.0.0",
+		},
+		"same finds same line": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.SamePrerelease,
+			releases: []impl.Release{{TagName: "v2.0.0-beta.4"}},
+			out:      "v2.0.0-beta.4",
+		},
+		"same finds same line marked prerelease": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.SamePrerelease,
+			releases: []impl.Release{{TagName: "v2.0.0-rc.1", Prerelease: true}},
+			out:      "v2.0.0-rc.1",
+		},
+		"same prefers stable": {
+			version: "v2.0.0-beta.3",
+			flags:   whatsnew.SamePrerelease,
+			releases: []impl.Release{
+				{TagName: "v2.0.0-beta.4"},
+				{TagName: "v2.0.0"},
+			},
+			out: "v2.0.0",
+		},
+		"same skips other line": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.SamePrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-alpha.1"}},
+			out:      "",
+		},
+		"same skips older": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.SamePrerelease,
+			releases: []impl.Release{{TagName: "v2.0.0-beta.2"}},
+			out:      "",
+		},
+		"same from stable skips prerelease": {
+			version:  "v2.0.0",
+			flags:    whatsnew.SamePrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-beta.1"}},
+			out:      "",
+		},
+		"across finds other line": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.AcrossPrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-alpha.1"}},
+			out:      "v2.1.0-alpha.1",
+		},
+		"across finds same line": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.AcrossPrerelease,
+			releases: []impl.Release{{TagName: "v2.0.0-beta.4"}},
+			out:      "v2.0.0-beta.4",
+		},
+		"across from stable skips prerelease": {
+			version:  "v2.0.0",
+			flags:    whatsnew.AcrossPrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-alpha.1"}},
+			out:      "",
+		},
+		"into from stable finds prerelease": {
+			version:  "v2.0.0",
+			flags:    whatsnew.IntoPrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-alpha.1"}},
+			out:      "v2.1.0-alpha.1",
+		},
+		"into from stable finds marked prerelease": {
+			version:  "v2.0.0",
+			flags:    whatsnew.IntoPrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0", Prerelease: true}},
+			out:      "v2.1.0",
+		},
+		"into from prerelease finds other line": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.IntoPrerelease,
+			releases: []impl.Release{{TagName: "v2.1.0-alpha.1"}},
+			out:      "v2.1.0-alpha.1",
+		},
+		"into prefers newest": {
+			version: "v2.0.0",
+			flags:   whatsnew.IntoPrerelease,
+			releases: []impl.Release{
+				{TagName: "v2.1.0-beta.1"},
+				{TagName: "v2.1.0"},
+				{TagName: "v2.2.0-alpha.1"},
+			},
+			out: "v2.2.0-alpha.1",
+		},
+		"combined flags": {
+			version: "v2.0.0-beta.3",
+			flags:   whatsnew.SamePrerelease | whatsnew.AcrossPrerelease,
+			releases: []impl.Release{
+				{TagName: "v2.0.0-beta.4"},
+				{TagName: "v2.1.0-alpha.1"},
+			},
+			out: "v2.1.0-alpha.1",
+		},
+		"drafts still skipped": {
+			version:  "v2.0.0-beta.3",
+			flags:    whatsnew.IntoPrerelease,
+			releases: []impl.Release{{TagName: "v2.0.0-beta.4", Draft: true}},
+			out:      "",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  tc.version,
+				Flags:    tc.flags,
+				Cacher:   &testCacher{info: &impl.Info{}},
+				Releaser: &testReleaser{releases: tc.releases},
+			})
+
+			res, err := fut.Get()
+			if res != tc.out {
+				t.Errorf("versions did not match. got: %s, want: %s", res, tc.out)
+			}
+			if err != nil {
+				t.Errorf("expected nil error. got: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheck_prereleaseFlagsFromCache(t *testing.T) {
+	ctx := context.Background()
+	tcs := map[string]struct {
+		version string
+		flags   whatsnew.Flag
+		info    impl.Info
+		out     string
+	}{
+		"upgraded off prerelease": {
+			version: "v2.0.0",
+			info:    impl.Info{Version: "v2.1.0-alpha.1", Prerelease: true},
+			out:     "",
+		},
+		"semver prerelease": {
+			version: "v2.0.0",
+			info:    impl.Info{Version: "v2.1.0-alpha.1"},
+			out:     "",
+		},
+		"marked prerelease": {
+			version: "v2.0.0",
+			info:    impl.Info{Version: "v2.1.0", Prerelease: true},
+			out:     "",
+		},
+		"still allowed": {
+			version: "v2.0.0",
+			flags:   whatsnew.IntoPrerelease,
+			info:    impl.Info{Version: "v2.1.0-alpha.1", Prerelease: true},
+			out:     "v2.1.0-alpha.1",
+		},
+		"stable": {
+			version: "v2.0.0",
+			info:    impl.Info{Version: "v2.1.0"},
+			out:     "v2.1.0",
+		},
+		"cached releases": {
+			version: "v2.0.0",
+			info: impl.Info{
+				Version:    "v2.1.0-alpha.1",
+				Prerelease: true,
+				Releases: []impl.Release{
+					{TagName: "v2.1.0-alpha.1", Prerelease: true},
+					{TagName: "v2.0.1"},
+				},
+			},
+			out: "v2.0.1",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			info := tc.info
+			info.CheckTime = time.Now()
+
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  tc.version,
+				Flags:    tc.flags,
+				Cacher:   &testCacher{info: &info},
+				Releaser: &testReleaser{},
+			})
+
+			res, err := fut.Get()
+			if res != tc.out {
+				t.Errorf("versions did not match. got: %s, want: %s", res, tc.out)
+			}
+			if err != nil {
+				t.Errorf("expected nil error. got: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheck_prereleaseFlagsChanged(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{}}
+	releaser := &testReleaser{
+		releases:    []impl.Release{{TagName: "v1.0.0"}, {TagName: "v1.1.0-rc.1"}},
+		notModified: true,
+	}
+
+	for _, tc := range []struct {
+		flags whatsnew.Flag
+		out   string
+	}{
+		{whatsnew.NoFlags, ""},
+		{whatsnew.IntoPrerelease, "v1.1.0-rc.1"},
+		{whatsnew.IntoPrerelease, "v1.1.0-rc.1"},
+		{whatsnew.NoFlags, ""},
+	} {
+		if cacher.set != nil {
+			cacher.info = cacher.set
+		}
+
+		fut := whatsnew.Check(ctx, &whatsnew.Options{
+			Version:  "v1.0.0",
+			Flags:    tc.flags,
+			Cacher:   cacher,
+			Releaser: releaser,
+		})
+
+		res, err := fut.Get()
+		if err != nil {
+			t.Fatalf("expected nil error. got: %s", err)
+		}
+		if res != tc.out {
+			t.Errorf("versions did not match with flags %d. got: %s, want: %s", tc.flags, res, tc.out)
+		}
+	}
+}
+
+func TestCheck_prereleaseFlagsChangedCacheReleases(t *testing.T) {
 	ctx := context.Background()
 	fut := whatsnew.Check(ctx, &whatsnew.Options{
-		Version:  "v1.0.0",
-		Cacher:   &testCacher{info: &impl.Info{Version: "v1.0.1"}},
-		Releaser: &testReleaser{err: errors.New("oops")},
+		Version:       "v1.0.0",
+		Flags:         whatsnew.IntoPrerelease,
+		CacheReleases: true,
+		Cacher: &testCacher{info: &impl.Info{
+			CheckTime: time.Now(),
+			Version:   "v1.1.0",
+			Releases: []impl.Release{
+				{TagName: "v1.2.0-rc.1", Prerelease: true},
+				{TagName: "v1.1.0"},
+			},
+		}},
+		Releaser: &testReleaser{err: errors.New("should not be called")},
 	})
 
 	res, err := fut.Get()
-	if res != "v1.0.1" {
-		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res != "v1.2.0-rc.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.2.0-rc.1")
+	}
+}
+
+func TestCheck_result(t *testing.T) {
+	ctx := context.Background()
+	published := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	lastCheck := time.Now().Add(-time.Hour).Round(0)
+
+	tcs := map[string]struct {
+		info        *impl.Info
+		releaser    *testReleaser
+		version     string
+		cached      bool
+		checkTime   time.Time
+		url         string
+		publishedAt time.Time
+	}{
+		"from network": {
+			info: &impl.Info{},
+			releaser: &testReleaser{releases: []impl.Release{{
+				TagName:     "v1.0.1",
+				HTMLURL:     "https://example.com/v1.0.1",
+				PublishedAt: published,
+			}}},
+			version:     "v1.0.1",
+			url:         "https://example.com/v1.0.1",
+			publishedAt: published,
+		},
+		"from network no update": {
+			info:     &impl.Info{},
+			releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.0"}}},
+		},
+		"not modified": {
+			info: &impl.Info{
+				Version:     "v1.0.1",
+				URL:         "https://example.com/v1.0.1",
+				PublishedAt: published,
+			},
+			releaser:    &testReleaser{},
+			version:     "v1.0.1",
+			url:         "https://example.com/v1.0.1",
+			publishedAt: published,
+		},
+		"from cache": {
+			info: &impl.Info{
+				CheckTime:   lastCheck,
+				Version:     "v1.0.1",
+				URL:         "https://example.com/v1.0.1",
+				PublishedAt: published,
+			},
+			releaser:    &testReleaser{err: errors.New("should not be called")},
+			version:     "v1.0.1",
+			cached:      true,
+			checkTime:   lastCheck,
+			url:         "https://example.com/v1.0.1",
+			publishedAt: published,
+		},
+		"from cache on releaser error": {
+			info:     &impl.Info{Version: "v1.0.1"},
+			releaser: &testReleaser{err: errors.New("oops")},
+			version:  "v1.0.1",
+			cached:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  "v1.0.0",
+				Cacher:   &testCacher{info: tc.info},
+				Releaser: tc.releaser,
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+
+			if res.Version != tc.version {
+				t.Errorf("versions did not match. got: %s, want: %s", res.Version, tc.version)
+			}
+			if tc.version == "" && res.Semver != nil {
+				t.Errorf("expected nil semver. got: %s", res.Semver)
+			}
+			if tc.version != "" && "v"+res.Semver.String() != tc.version {
+				t.Errorf("semver did not match. got: %s, want: %s", res.Semver, tc.version)
+			}
+			if res.Cached != tc.cached {
+				t.Errorf("cached did not match. got: %t, want: %t", res.Cached, tc.cached)
+			}
+			if tc.cached && !res.CheckTime.Equal(tc.checkTime) {
+				t.Errorf("check time did not match. got: %s, want: %s", res.CheckTime, tc.checkTime)
+			}
+			if !tc.cached && res.CheckTime.Before(start) {
+				t.Errorf("check time too old. got: %s, want after: %s", res.CheckTime, start)
+			}
+			if res.URL != tc.url {
+				t.Errorf("url did not match. got: %s, want: %s", res.URL, tc.url)
+			}
+			if !res.PublishedAt.Equal(tc.publishedAt) {
+				t.Errorf("published at did not match. got: %s, want: %s", res.PublishedAt, tc.publishedAt)
+			}
+		})
+	}
+}
+
+func TestCheck_resultRelease(t *testing.T) {
+	ctx := context.Background()
+	rel := impl.Release{
+		TagName: "v1.0.1",
+		Name:    "One oh one",
+		Body:    "Fixed some things.",
+		HTMLURL: "https://example.com/v1.0.1",
+		Assets:  []impl.Asset{{Name: "app.tar.gz", Size: 10}},
 	}
+
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   &testCacher{info: &impl.Info{}},
+		Releaser: &testReleaser{releases: []impl.Release{rel}},
+	})
+
+	res, err := fut.Result()
 	if err != nil {
-		t.Errorf("expected nil error. got: %s", err)
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if res.Release == nil {
+		t.Fatal("expected release but got none")
+	}
+	if res.Release.Name != rel.Name || res.Release.Body != rel.Body || len(res.Release.Assets) != 1 {
+		t.Errorf("release did not match. got: %+v, want: %+v", res.Release, rel)
 	}
 }
 
-func TestCheck_errOnBothCacheOptions(t *testing.T) {
+func TestCheck_resultReleaseFromCache(t *testing.T) {
 	ctx := context.Background()
 	fut := whatsnew.Check(ctx, &whatsnew.Options{
 		Version: "v1.0.0",
-		Slug:    "you/your-repo",
-		Cache:   "unused-cache.json",
-		Cacher:  &testCacher{info: &impl.Info{Version: "v1.0.1"}},
+		Cacher: &testCacher{info: &impl.Info{
+			CheckTime: time.Now(),
+			Version:   "v1.0.1",
+			Name:      "One oh one",
+			URL:       "https://example.com/v1.0.1",
+		}},
+		Releaser: &testReleaser{err: errors.New("should not be called")},
 	})
 
-	_, err := fut.Get()
-	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
-		t.Errorf("expected misconfigured error. got: %s", err)
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	want := impl.Release{TagName: "v1.0.1", Name: "One oh one", HTMLURL: "https://example.com/v1.0.1"}
+	if res.Release == nil || res.Release.TagName != want.TagName || res.Release.Name != want.Name || res.Release.HTMLURL != want.HTMLURL {
+		t.Errorf("release did not match. got: %+v, want: %+v", res.Release, want)
 	}
 }
 
-func TestCheck_errOnBothReleaserOptions(t *testing.T) {
+func TestCheck_resultOnMisconfiguredOptions(t *testing.T) {
 	ctx := context.Background()
 	fut := whatsnew.Check(ctx, &whatsnew.Options{
-		Version:  "v1.0.0",
-		Cache:    "unused-cache.json",
-		Slug:     "unused/slug",
-		Releaser: &testReleaser{err: errors.New("oops")},
+		Version: "v1.0.0",
+		Cache:   "unused-cache.json",
+		Cacher:  &testCacher{info: &impl.Info{}},
 	})
 
-	_, err := fut.Get()
+	res, err := fut.Result()
+	if res != nil {
+		t.Errorf("expected nil result. got: %v", res)
+	}
 	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
 		t.Errorf("expected misconfigured error. got: %s", err)
 	}
 }
 
-func TestRun_isRepeatable(t *testing.T) {
+func TestCheck_retraction(t *testing.T) {
+	ctx := context.Background()
+	rets := []impl.Retraction{
+		{Low: "v1.0.0", High: "v1.0.0", Rationale: "Published by accident."},
+		{Low: "v1.1.0", High: "v1.1.5", Rationale: "Bad parsing."},
+	}
+
+	tcs := map[string]struct {
+		version   string
+		info      *impl.Info
+		releaser  impl.Releaser
+		update    string
+		rationale string
+	}{
+		"retracted without update": {
+			version: "v1.0.0",
+			info:    &impl.Info{},
+			releaser: &testRetracter{
+				testReleaser: testReleaser{releases: []impl.Release{{TagName: "v1.0.0"}}},
+				retractions:  rets,
+			},
+			rationale: "Published by accident.",
+		},
+		"retracted with update": {
+			version: "v1.1.2",
+			info:    &impl.Info{},
+			releaser: &testRetracter{
+				testReleaser: testReleaser{releases: []impl.Release{{TagName: "v1.1.6"}}},
+				retractions:  rets,
+			},
+			update:    "v1.1.6",
+			rationale: "Bad parsing.",
+		},
+		"retracted range end": {
+			version: "v1.1.5",
+			info:    &impl.Info{},
+			releaser: &testRetracter{
+				testReleaser: testReleaser{releases: []impl.Release{{TagName: "v1.1.5"}}},
+				retractions:  rets,
+			},
+			rationale: "Bad parsing.",
+		},
+		"retracted update skipped": {
+			version: "v1.0.2",
+			info:    &impl.Info{},
+			releaser: &testRetracter{
+				testReleaser: testReleaser{releases: []impl.Release{{TagName: "v1.1.5"}, {TagName: "v1.0.3"}}},
+				retractions:  rets,
+			},
+			update: "v1.0.3",
+		},
+		"retracted update from cache skipped": {
+			version:  "v1.0.2",
+			info:     &impl.Info{CheckTime: time.Now(), Version: "v1.1.0", Retractions: rets},
+			releaser: &testRetracter{err: errors.New("should not be called")},
+		},
+		"not retracted": {
+			version:  "v1.1.6",
+			info:     &impl.Info{},
+			releaser: &testRetracter{retractions: rets},
+		},
+		"retracted from cache": {
+			version:   "v1.0.0",
+			info:      &impl.Info{CheckTime: time.Now(), Retractions: rets},
+			releaser:  &testRetracter{err: errors.New("should not be called")},
+			rationale: "Published by accident.",
+		},
+		"retractions kept from cache on error": {
+			version:   "v1.0.0",
+			info:      &impl.Info{Retractions: rets},
+			releaser:  &testRetracter{err: errors.New("oops")},
+			rationale: "Published by accident.",
+		},
+		"not a retracter": {
+			version:  "v1.0.0",
+			info:     &impl.Info{},
+			releaser: &testReleaser{},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  tc.version,
+				Cacher:   &testCacher{info: tc.info},
+				Releaser: tc.releaser,
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+
+			if res.Version != tc.update {
+				t.Errorf("versions did not match. got: %s, want: %s", res.Version, tc.update)
+			}
+
+			switch {
+			case tc.rationale == "" && res.Retraction != nil:
+				t.Errorf("expected no retraction. got: %+v", res.Retraction)
+			case tc.rationale != "" && res.Retraction == nil:
+				t.Error("expected retraction but got none")
+			case tc.rationale != "" && res.Retraction.Rationale != tc.rationale:
+				t.Errorf("rationale did not match. got: %s, want: %s", res.Retraction.Rationale, tc.rationale)
+			}
+		})
+	}
+}
+
+func TestCheck_retractionsNotModified(t *testing.T) {
+	rets := []impl.Retraction{{Low: "v1.0.0", High: "v1.0.0"}}
+	retracter := &testRetracter{retractions: []impl.Retraction{{Low: "v2.0.0", High: "v2.0.0"}}}
+
 	ctx := context.Background()
 	fut := whatsnew.Check(ctx, &whatsnew.Options{
 		Version:  "v1.0.0",
-		Cacher:   &testCacher{info: &impl.Info{}},
-		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+		Cacher:   &testCacher{info: &impl.Info{Etag: "some-etag", Retractions: rets}},
+		Releaser: retracter,
 	})
 
-	res, err := fut.Get()
-	if res != "v1.0.1" {
-		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
-	}
+	res, err := fut.Result()
 	if err != nil {
-		t.Errorf("expected nil error. got: %s", err)
+		t.Fatalf("expected nil error. got: %s", err)
 	}
 
-	res2, err2 := fut.Get()
-	if res != res2 {
-		t.Errorf("repeated versions did not match. got: %s, want: %s", res2, res)
+	if retracter.calls != 0 {
+		t.Errorf("expected retractions not to be fetched. got: %d calls", retracter.calls)
+	}
+	if res.Retraction == nil {
+		t.Error("expected cached retraction but got none")
 	}
+}
+
+func TestCheck_rateLimitSaved(t *testing.T) {
+	ctx := context.Background()
+	retry := time.Now().Add(time.Hour).Round(0)
+	cacher := &testCacher{info: &impl.Info{Version: "v1.0.1", Etag: "old-etag"}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.0.0",
+		Cacher:  cacher,
+		Releaser: &testReleaser{err: &impl.RateLimitError{
+			Status:     "403 Forbidden",
+			RetryAfter: retry,
+		}},
+	})
+
+	res, err := fut.Result()
 	if err != nil {
-		t.Errorf("repeated error not nil. got: %s", err2)
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v1.0.1" || !res.Cached {
+		t.Errorf("expected cached version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+
+	if cacher.set == nil {
+		t.Fatal("expected cache to be saved")
+	}
+	if !cacher.set.RetryAfter.Equal(retry) {
+		t.Errorf("wrong retry after. got: %s, want: %s", cacher.set.RetryAfter, retry)
+	}
+	if cacher.set.Version != "v1.0.1" || cacher.set.Etag != "old-etag" || !cacher.set.CheckTime.IsZero() {
+		t.Errorf("cached values not kept. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_rateLimitCapped(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:   "v1.0.0",
+		Frequency: time.Hour,
+		Cacher:    cacher,
+		Releaser: &testReleaser{err: &impl.RateLimitError{
+			Status:     "429 Too Many Requests",
+			RetryAfter: time.Now().Add(999999999 * time.Second),
+		}},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if cacher.set == nil {
+		t.Fatal("expected cache to be saved")
+	}
+	if limit := time.Now().Add(time.Hour); cacher.set.RetryAfter.After(limit) {
+		t.Errorf("retry after not capped. got: %s, want before: %s", cacher.set.RetryAfter, limit)
+	}
+}
+
+func TestCheck_rateLimitAlwaysCheck(t *testing.T) {
+	ctx := context.Background()
+	retry := time.Now().Add(time.Hour).Round(0)
+	cacher := &testCacher{info: &impl.Info{}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:   "v1.0.0",
+		Frequency: -time.Hour,
+		Cacher:    cacher,
+		Releaser: &testReleaser{err: &impl.RateLimitError{
+			Status:     "403 Forbidden",
+			RetryAfter: retry,
+		}},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if cacher.set == nil {
+		t.Fatal("expected cache to be saved")
+	}
+	if !cacher.set.RetryAfter.Equal(retry) {
+		t.Errorf("wrong retry after. got: %s, want: %s", cacher.set.RetryAfter, retry)
+	}
+}
+
+func TestCheck_rateLimitSkipsCheck(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{
+		Version:    "v1.0.1",
+		RetryAfter: time.Now().Add(time.Hour),
+	}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.2"}}},
+	})
+
+	res, err := fut.Get()
+	if res != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+	}
+	if err != nil {
+		t.Errorf("expected nil error. got: %s", err)
+	}
+	if cacher.set != nil {
+		t.Errorf("expected no cache update. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_rateLimitClearedAfterCheck(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{
+		Version:    "v1.0.1",
+		RetryAfter: time.Now().Add(-time.Minute),
+	}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.2"}}},
+	})
+
+	res, err := fut.Get()
+	if res != "v1.0.2" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.2")
+	}
+	if err != nil {
+		t.Errorf("expected nil error. got: %s", err)
+	}
+	if cacher.set == nil || !cacher.set.RetryAfter.IsZero() {
+		t.Errorf("expected retry after to be cleared. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_failureBackoff(t *testing.T) {
+	tcs := map[string]struct {
+		failures  int
+		frequency time.Duration
+		min, max  time.Duration
+	}{
+		"first failure":  {failures: 0, min: 30 * time.Second, max: time.Minute},
+		"second failure": {failures: 1, min: time.Minute, max: 2 * time.Minute},
+		"fifth failure":  {failures: 4, min: 8 * time.Minute, max: 16 * time.Minute},
+		"capped":         {failures: 20, frequency: time.Hour, min: 30 * time.Minute, max: time.Hour},
+		"many failures":  {failures: 1000, min: whatsnew.DefaultFrequency / 2, max: whatsnew.DefaultFrequency},
+		"always check":   {failures: 3, frequency: -time.Hour, min: 30 * time.Second, max: time.Minute},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lastCheck := time.Now().Add(-30 * 24 * time.Hour).Round(0)
+			cacher := &testCacher{info: &impl.Info{
+				CheckTime: lastCheck,
+				Version:   "v1.0.1",
+				Failures:  tc.failures,
+			}}
+
+			start := time.Now()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:   "v1.0.0",
+				Frequency: tc.frequency,
+				Cacher:    cacher,
+				Releaser:  &testReleaser{err: errors.New("offline")},
+			})
+
+			res, err := fut.Get()
+			if res != "v1.0.1" {
+				t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+			}
+			if err != nil {
+				t.Errorf("expected nil error. got: %s", err)
+			}
+
+			set := cacher.set
+			if set == nil {
+				t.Fatal("expected cache to be saved")
+			}
+			if set.Failures != tc.failures+1 {
+				t.Errorf("wrong failures. got: %d, want: %d", set.Failures, tc.failures+1)
+			}
+			if set.LastFailure.Before(start) {
+				t.Errorf("wrong last failure. got: %s, want after: %s", set.LastFailure, start)
+			}
+			if !set.CheckTime.Equal(lastCheck) {
+				t.Errorf("check time changed. got: %s, want: %s", set.CheckTime, lastCheck)
+			}
+
+			delay := set.RetryAfter.Sub(set.LastFailure)
+			if delay < tc.min || delay > tc.max {
+				t.Errorf("wrong backoff. got: %s, want between: %s and %s", delay, tc.min, tc.max)
+			}
+		})
+	}
+}
+
+func TestCheck_failureBackoffNegativeFailures(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testCacher{info: &impl.Info{Failures: -1}}
+
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{err: errors.New("offline")},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	set := cacher.set
+	if set == nil {
+		t.Fatal("expected cache to be saved")
+	}
+	if set.Failures != 1 {
+		t.Errorf("wrong failures. got: %d, want: %d", set.Failures, 1)
+	}
+	if delay := set.RetryAfter.Sub(set.LastFailure); delay < 30*time.Second || delay > time.Minute {
+		t.Errorf("wrong backoff. got: %s", delay)
+	}
+}
+
+func TestCheck_failuresResetOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	lastFailure := time.Now().Add(-time.Hour).Round(0)
+	cacher := &testCacher{info: &impl.Info{
+		Failures:    3,
+		LastFailure: lastFailure,
+		RetryAfter:  time.Now().Add(-time.Minute),
+	}}
+
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	set := cacher.set
+	if set == nil {
+		t.Fatal("expected cache to be saved")
+	}
+	if set.Failures != 0 || !set.RetryAfter.IsZero() {
+		t.Errorf("expected failures to be reset. got: %+v", set)
+	}
+	if !set.LastFailure.Equal(lastFailure) {
+		t.Errorf("last failure changed. got: %s, want: %s", set.LastFailure, lastFailure)
+	}
+}
+
+func TestCheck_canceledIsNotFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cacher := &testCacher{info: &impl.Info{}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{err: context.Canceled},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if cacher.set != nil {
+		t.Errorf("expected no cache update. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_leaseHeld(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testLeaser{testCacher: testCacher{info: &impl.Info{}}}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v1.0.1" || res.Cached {
+		t.Errorf("expected fresh version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+	if !cacher.released {
+		t.Error("expected lease to be released")
+	}
+}
+
+func TestCheck_leaseWaitsForOther(t *testing.T) {
+	ctx := context.Background()
+	checkTime := time.Now().Round(0)
+	cacher := &testLeaser{
+		testCacher: testCacher{info: &impl.Info{Version: "v1.0.1"}},
+		held:       true,
+		updated:    &impl.Info{CheckTime: checkTime, Version: "v1.0.2"},
+	}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v1.0.2" || !res.Cached || !res.CheckTime.Equal(checkTime) {
+		t.Errorf("expected other process's version. got: %s (cached: %t, check time: %s)", res.Version, res.Cached, res.CheckTime)
+	}
+	if cacher.set != nil {
+		t.Errorf("expected no cache update. got: %+v", cacher.set)
+	}
+}
+
+func TestCheck_leaseAfterOther(t *testing.T) {
+	ctx := context.Background()
+	checkTime := time.Now().Round(0)
+	cacher := &testLeaser{
+		testCacher: testCacher{info: &impl.Info{Version: "v1.0.1"}},
+		updated:    &impl.Info{CheckTime: checkTime, Version: "v1.0.2"},
+	}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v1.0.2" || !res.Cached || !res.CheckTime.Equal(checkTime) {
+		t.Errorf("expected other process's version. got: %s (cached: %t, check time: %s)", res.Version, res.Cached, res.CheckTime)
+	}
+	if cacher.set != nil {
+		t.Errorf("expected no cache update. got: %+v", cacher.set)
+	}
+	if !cacher.released {
+		t.Error("expected lease to be released")
+	}
+}
+
+func TestCheck_leaseWaitGivesUp(t *testing.T) {
+	ctx := context.Background()
+	cacher := &testLeaser{
+		testCacher: testCacher{info: &impl.Info{Version: "v1.0.1"}},
+		held:       true,
+	}
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{err: errors.New("should not be called")},
+	})
+
+	res, err := fut.Get()
+	if res != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+	}
+	if err != nil {
+		t.Errorf("expected nil error. got: %s", err)
+	}
+}
+
+// countingReleaser counts calls to Get, and is slow to respond.
+type countingReleaser struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingReleaser) Get(context.Context, string) ([]impl.Release, string, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	return []impl.Release{{TagName: "v1.0.1"}}, "some-etag", nil
+}
+
+func TestCheck_leaseDeduplicatesFileCacher(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.json")
+	rel := &countingReleaser{}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 20)
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  "v1.0.0",
+				Cacher:   &impl.FileCacher{Path: path},
+				Releaser: rel,
+			})
+
+			v, _ := fut.Get()
+			results <- v
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+
+	if rel.calls != 1 {
+		t.Errorf("expected a single release check. got: %d", rel.calls)
+	}
+	for v := range results {
+		if v != "v1.0.1" {
+			t.Errorf("versions did not match. got: %s, want: %s", v, "v1.0.1")
+		}
+	}
+}
+
+func TestCheck_fallsBackToCacheOnReleaserError(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   &testCacher{info: &impl.Info{Version: "v1.0.1"}},
+		Releaser: &testReleaser{err: errors.New("oops")},
+	})
+
+	res, err := fut.Get()
+	if res != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+	}
+	if err != nil {
+		t.Errorf("expected nil error. got: %s", err)
+	}
+}
+
+func TestCheck_errOnBothCacheOptions(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version: "v1.0.0",
+		Slug:    "you/your-repo",
+		Cache:   "unused-cache.json",
+		Cacher:  &testCacher{info: &impl.Info{Version: "v1.0.1"}},
+	})
+
+	_, err := fut.Get()
+	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
+	}
+}
+
+func TestCheck_errOnBothReleaserOptions(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cache:    "unused-cache.json",
+		Slug:     "unused/slug",
+		Releaser: &testReleaser{err: errors.New("oops")},
+	})
+
+	_, err := fut.Get()
+	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
+	}
+}
+
+func TestCheck_errOnGiteaAndReleaserOptions(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   &testCacher{info: &impl.Info{}},
+		GiteaURL: "https://codeberg.org",
+		Releaser: &testReleaser{err: errors.New("oops")},
+	})
+
+	_, err := fut.Get()
+	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
+	}
+}
+
+func TestCheck_errOnSharedCacheOptions(t *testing.T) {
+	tcs := map[string]*whatsnew.Options{
+		"cache": {
+			Slug:        "you/your-app",
+			Cache:       "unused-cache.json",
+			SharedCache: "unused-shared-cache.json",
+		},
+		"cacher": {
+			Slug:        "you/your-app",
+			Cacher:      &testCacher{info: &impl.Info{}},
+			SharedCache: "unused-shared-cache.json",
+		},
+		"no key": {
+			Releaser:    &testReleaser{},
+			SharedCache: "unused-shared-cache.json",
+		},
+	}
+
+	for name, opts := range tcs {
+		t.Run(name, func(t *testing.T) {
+			opts.Version = "v1.0.0"
+			_, err := whatsnew.Check(context.Background(), opts).Get()
+			if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+				t.Errorf("expected misconfigured error. got: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheck_sharedCache(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	apps := map[string]string{
+		"you/your-app":  "v1.0.1",
+		"you/other-app": "v2.0.1",
+	}
+
+	for key, v := range apps {
+		fut := whatsnew.Check(ctx, &whatsnew.Options{
+			Version:     "v1.0.0",
+			SharedCache: path,
+			CacheKey:    key,
+			Releaser:    &testReleaser{releases: []impl.Release{{TagName: v}}},
+		})
+		if _, err := fut.Get(); err != nil {
+			t.Fatalf("expected nil error. got: %s", err)
+		}
+	}
+
+	// Each application's result is cached separately.
+	for key, v := range apps {
+		fut := whatsnew.Check(ctx, &whatsnew.Options{
+			Version:     "v1.0.0",
+			SharedCache: path,
+			CacheKey:    key,
+			Releaser:    &testReleaser{err: errors.New("should not be called")},
+		})
+
+		res, err := fut.Result()
+		if err != nil {
+			t.Fatalf("expected nil error. got: %s", err)
+		}
+		if res.Version != v || !res.Cached {
+			t.Errorf("versions did not match. got: %s (cached: %t), want: %s", res.Version, res.Cached, v)
+		}
+	}
+}
+
+func TestCheck_sharedCacheKeyedBySlug(t *testing.T) {
+	tcs := map[string]string{
+		"owner/repo": "you/your-app",
+		"with host":  "github.com/you/your-app",
+		"url":        "https://github.com/you/your-app.git",
+		"scp-like":   "git@github.com:you/your-app.git",
+	}
+
+	for name, slug := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "updates.json")
+
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:        slug,
+				Version:     "v0.0.1",
+				SharedCache: path,
+			})
+			if v, _ := fut.Get(); v != "0.30.0" {
+				t.Errorf("versions did not match. got: %s, want: %s", v, "0.30.0")
+			}
+
+			kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+			i, err := kc.Get(ctx)
+			if err != nil {
+				t.Fatalf("expected cache entry. got: %s", err)
+			}
+			if i.Version != "0.30.0" {
+				t.Errorf("versions did not match. got: %s, want: %s", i.Version, "0.30.0")
+			}
+		})
+	}
+}
+
+func TestCheck_giteaURL(t *testing.T) {
+	tcs := map[string]struct {
+		token string
+		auth  string
+	}{
+		"no token": {"", ""},
+		"token":    {"secret-token", "token secret-token"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rt := recordRequests(t)
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:       "you/your-app",
+				Version:    "v0.0.1",
+				Cacher:     &testCacher{info: &impl.Info{}},
+				GiteaURL:   "https://codeberg.org",
+				GiteaToken: tc.token,
+			})
+
+			res, err := fut.Get()
+			if res != "v0.31.0" {
+				t.Errorf("versions did not match. got: %s, want: %s", res, "v0.31.0")
+			}
+			if err != nil {
+				t.Errorf("expected nil error. got: %s", err)
+			}
+			if auth := rt.req.Header.Get("Authorization"); auth != tc.auth {
+				t.Errorf("wrong authorization. got: %q, want: %q", auth, tc.auth)
+			}
+		})
+	}
+}
+
+// recordTransport records the last request before handing off to the
+// next RoundTripper.
+type recordTransport struct {
+	next http.RoundTripper
+	req  *http.Request
+}
+
+func (rt *recordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.req = r
+	return rt.next.RoundTrip(r)
+}
+
+// recordRequests replaces the default transport with a recordTransport
+// for the duration of the test.
+func recordRequests(t *testing.T) *recordTransport {
+	rt := &recordTransport{next: http.DefaultTransport}
+	http.DefaultTransport = rt
+	t.Cleanup(func() { http.DefaultTransport = rt.next })
+
+	return rt
+}
+
+func TestCheck_githubToken(t *testing.T) {
+	tcs := map[string]struct {
+		env   string
+		opt   string
+		token string
+	}{
+		"discovered": {env: "env-token", token: "env-token"},
+		"explicit":   {env: "env-token", opt: "opt-token", token: "opt-token"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testenv.Setenv(t, "GH_TOKEN", tc.env)
+
+			rt := recordRequests(t)
+
+			cache := filepath.Join(t.TempDir(), "cache.json")
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:        "you/your-app",
+				Cache:       cache,
+				Version:     "v0.0.1",
+				GitHubToken: tc.opt,
+			})
+
+			if _, err := fut.Get(); err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+
+			if auth := rt.req.Header.Get("Authorization"); auth != "Bearer "+tc.token {
+				t.Errorf("wrong authorization. got: %q, want: %q", auth, "Bearer "+tc.token)
+			}
+
+			b, err := os.ReadFile(cache)
+			if err != nil {
+				t.Fatalf("could not read cache: %s", err)
+			}
+			if strings.Contains(string(b), tc.token) {
+				t.Errorf("token leaked in cache: %s", b)
+			}
+		})
+	}
+}
+
+func TestCheck_githubAPIURL(t *testing.T) {
+	tcs := map[string]struct {
+		slug   string
+		apiURL string
+		env    string
+		url    string
+		out    string
+	}{
+		"slug": {
+			slug: "you/your-app",
+			url:  "https://api.github.com/repos/you/your-app/releases?per_page=100",
+			out:  "0.30.0",
+		},
+		"https url": {
+			slug: "https://github.com/you/your-app",
+			url:  "https://api.github.com/repos/you/your-app/releases?per_page=100",
+			out:  "0.30.0",
+		},
+		"git url": {
+			slug: "https://github.com/you/your-app.git/",
+			url:  "https://api.github.com/repos/you/your-app/releases?per_page=100",
+			out:  "0.30.0",
+		},
+		"host slug": {
+			slug: "github.com/you/your-app",
+			url:  "https://api.github.com/repos/you/your-app/releases?per_page=100",
+			out:  "0.30.0",
+		},
+		"scp-like": {
+			slug: "git@ghe.example.com:you/your-app.git",
+			url:  "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:  "v0.32.0",
+		},
+		"ssh url": {
+			slug: "ssh://git@ghe.example.com:2222/you/your-app.git",
+			url:  "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:  "v0.32.0",
+		},
+		"api url option": {
+			slug:   "you/your-app",
+			apiURL: "https://ghe.example.com/api/v3/",
+			url:    "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:    "v0.32.0",
+		},
+		"api url option overrides slug": {
+			slug:   "https://github.com/you/your-app",
+			apiURL: "https://ghe.example.com/api/v3",
+			env:    "https://ignored.example.com/api/v3",
+			url:    "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:    "v0.32.0",
+		},
+		"api url env": {
+			slug: "you/your-app",
+			env:  "https://ghe.example.com/api/v3",
+			url:  "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:  "v0.32.0",
+		},
+		"slug host overrides env": {
+			slug: "git@ghe.example.com:you/your-app.git",
+			env:  "https://api.github.com",
+			url:  "https://ghe.example.com/api/v3/repos/you/your-app/releases?per_page=100",
+			out:  "v0.32.0",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testenv.Setenv(t, "GITHUB_API_URL", tc.env)
+			rt := recordRequests(t)
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:         tc.slug,
+				Version:      "v0.0.1",
+				Cacher:       &testCacher{info: &impl.Info{}},
+				GitHubAPIURL: tc.apiURL,
+			})
+
+			res, err := fut.Get()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if res != tc.out {
+				t.Errorf("versions did not match. got: %s, want: %s", res, tc.out)
+			}
+			if rt.req.URL.String() != tc.url {
+				t.Errorf("wrong url. got: %s, want: %s", rt.req.URL, tc.url)
+			}
+		})
+	}
+}
+
+func TestCheck_errOnBadSlug(t *testing.T) {
+	for _, slug := range []string{"", "you", "you/your-app/extra", "https://github.com/you", "git@github.com:"} {
+		t.Run(slug, func(t *testing.T) {
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:    slug,
+				Version: "v1.0.0",
+				Cacher:  &testCacher{info: &impl.Info{}},
+			})
+
+			_, err := fut.Get()
+			if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+				t.Errorf("expected misconfigured error. got: %s", err)
+			}
+		})
+	}
+}
+
+func TestRun_isRepeatable(t *testing.T) {
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   &testCacher{info: &impl.Info{}},
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	res, err := fut.Get()
+	if res != "v1.0.1" {
+		t.Errorf("versions did not match. got: %s, want: %s", res, "v1.0.1")
+	}
+	if err != nil {
+		t.Errorf("expected nil error. got: %s", err)
+	}
+
+	res2, err2 := fut.Get()
+	if res != res2 {
+		t.Errorf("repeated versions did not match. got: %s, want: %s", res2, res)
+	}
+	if err != nil {
+		t.Errorf("repeated error not nil. got: %s", err2)
+	}
+}
+
+func TestDefaultCachePath(t *testing.T) {
+	tcs := map[string]struct {
+		slug     string
+		xdg      string
+		override string
+		out      string
+	}{
+		"xdg cache home": {"you/your-app", "/xdg/cache", "", filepath.Join("/xdg/cache", "whatsnew", "you", "your-app.json")},
+		"slug url":       {"https://github.com/you/your-app.git", "/xdg/cache", "", filepath.Join("/xdg/cache", "whatsnew", "you", "your-app.json")},
+		"other owner":    {"them/your-app", "/xdg/cache", "", filepath.Join("/xdg/cache", "whatsnew", "them", "your-app.json")},
+		"other host":     {"ghe.example.com/you/your-app", "/xdg/cache", "", filepath.Join("/xdg/cache", "whatsnew", "ghe.example.com", "you", "your-app.json")},
+		"app override":   {"you/your-app", "/xdg/cache", "/elsewhere/cache.json", "/elsewhere/cache.json"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testenv.Setenv(t, "XDG_CACHE_HOME", tc.xdg)
+			testenv.Setenv(t, "YOUR_APP_WHATSNEW_CACHE", tc.override)
+
+			out, err := whatsnew.DefaultCachePath(tc.slug)
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+			if out != tc.out {
+				t.Errorf("paths did not match. got: %s, want: %s", out, tc.out)
+			}
+		})
+	}
+}
+
+func TestDefaultCachePath_errOnBadSlug(t *testing.T) {
+	_, err := whatsnew.DefaultCachePath("you")
+	if !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
+	}
+}
+
+func TestCheck_defaultCache(t *testing.T) {
+	dir := t.TempDir()
+	testenv.Setenv(t, "XDG_CACHE_HOME", dir)
+	testenv.Setenv(t, "YOUR_APP_WHATSNEW_CACHE", "")
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-app",
+		Version: "v0.0.1",
+	})
+
+	if v, _ := fut.Get(); v != "0.30.0" {
+		t.Errorf("versions did not match. got: %s, want: %s", v, "0.30.0")
+	}
+
+	fc := impl.FileCacher{Path: filepath.Join(dir, "whatsnew", "you", "your-app.json")}
+	i, err := fc.Get(ctx)
+	if err != nil {
+		t.Fatalf("expected default cache to be written. got: %s", err)
+	}
+	if i.Version != "0.30.0" {
+		t.Errorf("versions did not match. got: %s, want: %s", i.Version, "0.30.0")
+	}
+}
+
+func TestCheck_defaultCacheExisting(t *testing.T) {
+	dir := t.TempDir()
+	testenv.Setenv(t, "XDG_CACHE_HOME", dir)
+	testenv.Setenv(t, "YOUR_APP_WHATSNEW_CACHE", "")
+
+	ctx := context.Background()
+	fc := impl.FileCacher{Path: filepath.Join(dir, "whatsnew", "you", "your-app.json")}
+	if err := fc.Set(ctx, &impl.Info{CheckTime: time.Now(), Version: "v0.2.0"}); err != nil {
+		t.Fatalf("couldn't set up cache: %s", err)
+	}
+
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-app",
+		Version: "v0.0.1",
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v0.2.0" || !res.Cached {
+		t.Errorf("expected cached version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+}
+
+func TestCheck_defaultCacheNotWritable(t *testing.T) {
+	// A cache home that is a file can't hold the cache directory.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal("couldn't set up cache home")
+	}
+	testenv.Setenv(t, "XDG_CACHE_HOME", file)
+	testenv.Setenv(t, "YOUR_APP_WHATSNEW_CACHE", "")
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Slug:    "you/your-app",
+		Version: "v0.0.1",
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "0.30.0" || res.Cached {
+		t.Errorf("expected fresh version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+}
+
+func TestCheck_corruptCache(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(cache, []byte(`{"check_time": "2021-03-01T10:00:00Z", "vers`), 0600); err != nil {
+		t.Fatal("couldn't set up cache")
+	}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cache:    cache,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	var cerr *impl.CorruptCacheError
+	if !errors.As(res.CacheErr, &cerr) {
+		t.Fatalf("expected corrupt cache error. got: %v", res.CacheErr)
+	}
+	if _, err := os.Stat(cerr.Quarantine); err != nil {
+		t.Errorf("expected corrupt cache to be kept. got: %v", err)
+	}
+
+	if res.Version != "v1.0.1" || res.Cached {
+		t.Errorf("expected fresh version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+
+	fc := impl.FileCacher{Path: cache}
+	if i, err := fc.Get(ctx); err != nil || i.Version != "v1.0.1" {
+		t.Errorf("expected fresh cache. got: %+v, err: %v", i, err)
+	}
+}
+
+func TestCheck_sharedCacheClockSkew(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	kc := impl.KeyedFileCacher{Path: path, Key: "you/your-app"}
+	if err := kc.Set(ctx, &impl.Info{CheckTime: time.Now().Add(time.Hour), Version: "v1.0.0"}); err != nil {
+		t.Fatalf("couldn't set up cache: %s", err)
+	}
+
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:     "v1.0.0",
+		SharedCache: path,
+		CacheKey:    "you/your-app",
+		Releaser:    &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	var cerr *impl.CorruptCacheError
+	if !errors.As(res.CacheErr, &cerr) {
+		t.Fatalf("expected corrupt cache error. got: %v", res.CacheErr)
+	}
+	if cerr.Quarantine != "" {
+		t.Errorf("expected shared cache to be kept. got: %s", cerr.Quarantine)
+	}
+
+	if res.Version != "v1.0.1" || res.Cached {
+		t.Errorf("expected fresh version. got: %s (cached: %t)", res.Version, res.Cached)
+	}
+
+	if i, err := kc.Get(ctx); err != nil || i.Version != "v1.0.1" {
+		t.Errorf("expected fresh cache. got: %+v, err: %v", i, err)
+	}
+}
+
+func TestCheck_cacheReleases(t *testing.T) {
+	body := strings.Repeat("é", 8<<10)
+	cacher := &testCacher{info: &impl.Info{}}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:       "v1.0.0",
+		CacheReleases: true,
+		Cacher:        cacher,
+		Releaser: &testReleaser{releases: []impl.Release{
+			{TagName: "v1.0.1", Name: "patch", Body: "fixes"},
+			{TagName: "v1.2.0", Draft: true},
+			{TagName: "not-semver"},
+			{TagName: "v1.1.0", Name: "minor", Body: body, Assets: []impl.Asset{{Name: "your-app.tar.gz"}}},
+			{TagName: "v1.1.1-rc.1", Prerelease: true},
+		}},
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if res.Version != "v1.1.0" || len(res.Release.Assets) != 1 {
+		t.Errorf("expected full release. got: %+v", res.Release)
+	}
+
+	var tags []string
+	for _, rel := range cacher.set.Releases {
+		tags = append(tags, rel.TagName)
+		if rel.Assets != nil {
+			t.Errorf("expected assets to be dropped. got: %+v", rel.Assets)
+		}
+	}
+
+	if got, want := strings.Join(tags, ","), "v1.1.1-rc.1,v1.1.0,v1.0.1"; got != want {
+		t.Errorf("cached releases did not match. got: %s, want: %s", got, want)
+	}
+
+	cached := cacher.set.Releases[1].Body
+	if len(cached) > 8<<10 || !strings.HasPrefix(body, cached) || !utf8.ValidString(cached) {
+		t.Errorf("expected truncated body. got %d bytes", len(cached))
+	}
+}
+
+func TestCheck_cacheReleasesNotModified(t *testing.T) {
+	cacher := &testCacher{info: &impl.Info{
+		Version:  "v1.0.1",
+		Etag:     "some-etag",
+		Releases: []impl.Release{{TagName: "v1.0.1", Name: "patch", Body: "fixes"}},
+	}}
+	rel := &testReleaser{}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:       "v1.0.0",
+		CacheReleases: true,
+		Cacher:        cacher,
+		Releaser:      rel,
+	})
+
+	res, err := fut.Result()
+	if err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if rel.etag != "some-etag" {
+		t.Errorf("etags did not match. got: %s, want: %s", rel.etag, "some-etag")
+	}
+	if res.Cached || res.Release == nil || res.Release.Body != "fixes" {
+		t.Errorf("expected cached release. got: %+v (cached: %t)", res.Release, res.Cached)
+	}
+	if len(cacher.set.Releases) != 1 {
+		t.Errorf("expected cached releases to be kept. got: %+v", cacher.set.Releases)
+	}
+}
+
+func TestCheck_cacheReleasesRefetch(t *testing.T) {
+	cacher := &testCacher{info: &impl.Info{Version: "v1.0.1", Etag: "some-etag"}}
+	rel := &testReleaser{releases: []impl.Release{{TagName: "v1.0.1", Body: "fixes"}}}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:       "v1.0.0",
+		CacheReleases: true,
+		Cacher:        cacher,
+		Releaser:      rel,
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	// Without cached releases, the etag is not sent.
+	if rel.etag != "" {
+		t.Errorf("expected no etag. got: %s", rel.etag)
+	}
+	if len(cacher.set.Releases) != 1 {
+		t.Errorf("expected releases to be cached. got: %+v", cacher.set.Releases)
+	}
+}
+
+func TestCheck_cacheReleasesDisabled(t *testing.T) {
+	cacher := &testCacher{info: &impl.Info{
+		Releases: []impl.Release{{TagName: "v1.0.1"}},
+	}}
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Cacher:   cacher,
+		Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.0.1"}}},
+	})
+
+	if _, err := fut.Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+	if cacher.set.Releases != nil {
+		t.Errorf("expected no cached releases. got: %+v", cacher.set.Releases)
+	}
+}
+
+func TestCheck_upgraded(t *testing.T) {
+	tcs := map[string]struct {
+		lastRun  string
+		upgraded bool
+		saved    bool
+	}{
+		"first run":   {"", false, false},
+		"same":        {"v1.2.0", false, false},
+		"upgrade":     {"v1.0.0", true, true},
+		"downgrade":   {"v1.3.0", false, true},
+		"bad version": {"not-semver", false, true},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			cacher := &testCacher{info: &impl.Info{CheckTime: time.Now(), LastRunVersion: tc.lastRun}}
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Version:  "v1.2.0",
+				Cacher:   cacher,
+				Releaser: &testReleaser{err: errors.New("should not be called")},
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+
+			if res.Upgraded != tc.upgraded {
+				t.Errorf("upgraded did not match. got: %t, want: %t", res.Upgraded, tc.upgraded)
+			}
+			if tc.upgraded && res.Previous != tc.lastRun {
+				t.Errorf("previous did not match. got: %s, want: %s", res.Previous, tc.lastRun)
+			}
+
+			if saved := cacher.set != nil; saved != tc.saved {
+				t.Fatalf("expected cache update: %t. got: %+v", tc.saved, cacher.set)
+			}
+			if tc.saved && cacher.set.LastRunVersion != "v1.2.0" {
+				t.Errorf("last run version did not match. got: %s, want: %s", cacher.set.LastRunVersion, "v1.2.0")
+			}
+		})
+	}
+}
+
+func TestCheck_upgradedOnce(t *testing.T) {
+	ctx := context.Background()
+	cacher := &impl.FileCacher{Path: filepath.Join(t.TempDir(), "cache.json")}
+
+	for n, tc := range []struct {
+		version  string
+		upgraded bool
+	}{
+		{"v1.0.0", false},
+		{"v1.1.0", true},
+		{"v1.1.0", false},
+	} {
+		fut := whatsnew.Check(ctx, &whatsnew.Options{
+			Version:  tc.version,
+			Cacher:   cacher,
+			Releaser: &testReleaser{releases: []impl.Release{{TagName: "v1.1.0"}}},
+		})
+
+		res, err := fut.Result()
+		if err != nil {
+			t.Fatalf("expected nil error. got: %s", err)
+		}
+		if res.Upgraded != tc.upgraded {
+			t.Errorf("run %d: upgraded did not match. got: %t, want: %t", n, res.Upgraded, tc.upgraded)
+		}
+	}
+}
+
+func TestCheck_skipped(t *testing.T) {
+	tcs := map[string]struct {
+		env    string
+		val    string
+		reason string
+	}{
+		"ci":             {"CI", "true", "running in CI (CI is set)"},
+		"github actions": {"GITHUB_ACTIONS", "true", "running in CI (GITHUB_ACTIONS is set)"},
+		"jenkins":        {"JENKINS_URL", "https://ci.example.com", "running in CI (JENKINS_URL is set)"},
+		"disable":        {"WHATSNEW_DISABLE", "1", "WHATSNEW_DISABLE is set"},
+		"app":            {"YOUR_APP_NO_UPDATE_CHECK", "1", "YOUR_APP_NO_UPDATE_CHECK is set"},
+		"do not track":   {"DO_NOT_TRACK", "1", "DO_NOT_TRACK is set"},
+		"zero":           {"DO_NOT_TRACK", "0", ""},
+		"false":          {"CI", "false", ""},
+		"other app":      {"THEIR_APP_NO_UPDATE_CHECK", "1", ""},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testenv.Setenv(t, tc.env, tc.val)
+
+			cacher := &testCacher{info: &impl.Info{CheckTime: time.Now(), Version: "v1.0.1"}}
+
+			ctx := context.Background()
+			fut := whatsnew.Check(ctx, &whatsnew.Options{
+				Slug:    "you/your-app",
+				Version: "v1.0.0",
+				Cacher:  cacher,
+			})
+
+			res, err := fut.Result()
+			if err != nil {
+				t.Fatalf("expected nil error. got: %s", err)
+			}
+
+			if res.Skipped != tc.reason {
+				t.Errorf("skip reason did not match. got: %q, want: %q", res.Skipped, tc.reason)
+			}
+
+			want := "v1.0.1"
+			if tc.reason != "" {
+				want = ""
+			}
+			if res.Version != want {
+				t.Errorf("versions did not match. got: %s, want: %s", res.Version, want)
+			}
+		})
+	}
+}
+
+func TestCheck_skippedWithoutSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	testenv.Setenv(t, "CI", "true")
+	testenv.Setenv(t, "XDG_CACHE_HOME", dir)
+	testenv.Setenv(t, "YOUR_APP_WHATSNEW_CACHE", "")
+
+	ctx := context.Background()
+	opts := &whatsnew.Options{
+		Slug:    "you/your-app",
+		Version: "v1.0.0",
+	}
+	if _, err := whatsnew.Check(ctx, opts).Get(); err != nil {
+		t.Fatalf("expected nil error. got: %s", err)
+	}
+
+	if opts.Releaser != nil || opts.Cacher != nil {
+		t.Errorf("expected releaser and cacher not to be resolved. got: %T, %T", opts.Releaser, opts.Cacher)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing written to cache dir. got: %d entries", len(entries))
+	}
+}
+
+func TestCheck_skippedStillErrOnMisconfiguredOptions(t *testing.T) {
+	testenv.Setenv(t, "CI", "true")
+
+	ctx := context.Background()
+	fut := whatsnew.Check(ctx, &whatsnew.Options{
+		Version:  "v1.0.0",
+		Slug:     "you/your-app",
+		Releaser: &testReleaser{},
+	})
+
+	if _, err := fut.Get(); !errors.Is(err, whatsnew.ErrMisconfiguredOptions) {
+		t.Errorf("expected misconfigured error. got: %s", err)
 	}
 }